@@ -0,0 +1,23 @@
+package ast
+
+// AssignStatement assigns a value to a field of a struct, an entry of a map, or an element of a slice,
+// such as "let user.Name = x" or "let h["key"] = x".
+type AssignStatement struct {
+	StartLine int
+	StartCol  int
+	Target    *FieldExpression
+	Expression
+}
+
+func (a *AssignStatement) Line() int {
+	return a.StartLine
+}
+
+func (a *AssignStatement) Col() int {
+	return a.StartCol
+}
+
+func (a *AssignStatement) statement() {}
+
+var _ Node = (*AssignStatement)(nil)
+var _ Statement = (*AssignStatement)(nil)
@@ -5,6 +5,7 @@ type Block struct {
 	StartLine  int
 	StartCol   int
 	Statements []Statement
+	Span
 }
 
 func (b *Block) Line() int {
@@ -16,3 +17,28 @@ func (b *Block) Col() int {
 }
 
 var _ Node = (*Block)(nil)
+
+// BlockExpression is a named block, such as `block "content" ... end`, that may be overridden by templates
+// extending the template it is defined in (see ExtendsStatement.) If the block is not overridden, its own
+// statements are executed, capturing their return values like a CaptureExpression.
+type BlockExpression struct {
+	StartLine int
+	StartCol  int
+	Name      string
+	Block
+}
+
+func (b *BlockExpression) Line() int {
+	return b.StartLine
+}
+
+func (b *BlockExpression) Col() int {
+	return b.StartCol
+}
+
+func (b *BlockExpression) expression() {
+}
+
+var _ Node = (*BlockExpression)(nil)
+var _ Expression = (*BlockExpression)(nil)
+var _ Spanned = (*Block)(nil)
@@ -5,6 +5,14 @@ type BoolLiteral struct {
 	StartLine int
 	StartCol  int
 	Value     bool
+	Span
+}
+
+// NewBoolLiteral returns a new literal bool value.
+func NewBoolLiteral(v bool) *BoolLiteral {
+	return &BoolLiteral{
+		Value: v,
+	}
 }
 
 func (b *BoolLiteral) Line() int {
@@ -19,3 +27,4 @@ func (b *BoolLiteral) expression() {}
 
 var _ Node = (*BoolLiteral)(nil)
 var _ Expression = (*BoolLiteral)(nil)
+var _ Spanned = (*BoolLiteral)(nil)
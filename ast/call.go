@@ -1,16 +1,32 @@
 package ast
 
 // CallExpression calls a method or function. The called method or function may return zero or more values.
-// If no values are returned, the CallExpression returns nil, otherwise only the first value is returned.
-// If the method or function returns an error as the last value, execution stops with that error.
+// If the method or function returns an error as the last value, execution stops with that error if it is not nil.
+// Otherwise, the error value is discarded. If no other values are returned, the CallExpression returns nil.
+// This means that a method or function that only returns an error never contributes a value.
+// If exactly one other value is returned, the CallExpression returns that value. If more values are returned,
+// the CallExpression returns all of them as a slice, in order.
 //
 // Not all method or function arguments need to be supplied. Any remaining arguments not supplied can be
-// supplied automatically by a evaluator.ResolveArgumentFunc.
+// supplied automatically by a evaluator.ResolveArgumentFunc. If the method or function is variadic,
+// any number of arguments may be supplied for the variadic parameter, including none.
+//
+// If an argument is a hash and the method or function expects a struct, a new struct is passed instead,
+// with its exported fields populated from the hash's entries by name.
 type CallExpression struct {
 	StartLine int
 	StartCol  int
 	Callee    Expression
 	Params    []Expression
+	Span
+}
+
+// NewCall returns a new expression that calls callee with params.
+func NewCall(callee Expression, params ...Expression) *CallExpression {
+	return &CallExpression{
+		Callee: callee,
+		Params: params,
+	}
 }
 
 func (c *CallExpression) Line() int {
@@ -25,3 +41,4 @@ func (c *CallExpression) expression() {}
 
 var _ Node = (*CallExpression)(nil)
 var _ Expression = (*CallExpression)(nil)
+var _ Spanned = (*CallExpression)(nil)
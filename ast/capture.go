@@ -6,6 +6,7 @@ type CaptureExpression struct {
 	StartLine int
 	StartCol  int
 	Block
+	Span
 }
 
 func (c *CaptureExpression) Line() int {
@@ -21,3 +22,4 @@ func (c *CaptureExpression) expression() {
 
 var _ Node = (*CallExpression)(nil)
 var _ Expression = (*CallExpression)(nil)
+var _ Spanned = (*CaptureExpression)(nil)
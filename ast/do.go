@@ -0,0 +1,25 @@
+package ast
+
+// DoExpression executes the statements in its block in a new scope, and returns the return value of the last
+// statement only. This is unlike CaptureExpression, which returns the return values of all statements.
+type DoExpression struct {
+	StartLine int
+	StartCol  int
+	Block
+	Span
+}
+
+func (d *DoExpression) Line() int {
+	return d.StartLine
+}
+
+func (d *DoExpression) Col() int {
+	return d.StartCol
+}
+
+func (d *DoExpression) expression() {
+}
+
+var _ Node = (*DoExpression)(nil)
+var _ Expression = (*DoExpression)(nil)
+var _ Spanned = (*DoExpression)(nil)
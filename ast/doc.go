@@ -1,3 +1,6 @@
 // Package ast provides types representing nodes of an abstract syntax tree.
 // The abstract syntax tree can be evaluated (executed) using an evaluator.Evaluator.
+//
+// The New* constructors can be used to build an abstract syntax tree programmatically. They leave the
+// position of the nodes they return unset.
 package ast
@@ -14,6 +14,13 @@ type ExpressionStatement struct {
 	Expression
 }
 
+// NewExpressionStatement returns a new statement that evaluates e.
+func NewExpressionStatement(e Expression) *ExpressionStatement {
+	return &ExpressionStatement{
+		Expression: e,
+	}
+}
+
 func (e *ExpressionStatement) Line() int {
 	return e.StartLine
 }
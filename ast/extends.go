@@ -0,0 +1,26 @@
+package ast
+
+// ExtendsStatement declares that a template extends another template, such as `extends "layout"`.
+// Rendering such a template renders the extended template instead, replacing its blocks with the blocks
+// of the same names defined in the extending template (see BlockExpression.)
+//
+// Extending templates is resolved by the template renderer, so the statement itself does nothing when it
+// is evaluated.
+type ExtendsStatement struct {
+	StartLine int
+	StartCol  int
+	Name      string
+}
+
+func (e *ExtendsStatement) Line() int {
+	return e.StartLine
+}
+
+func (e *ExtendsStatement) Col() int {
+	return e.StartCol
+}
+
+func (e *ExtendsStatement) statement() {}
+
+var _ Node = (*ExtendsStatement)(nil)
+var _ Statement = (*ExtendsStatement)(nil)
@@ -7,6 +7,15 @@ type FieldExpression struct {
 	StartCol  int
 	Callee    Expression
 	Index     Expression
+	Span
+}
+
+// NewField returns a new expression that looks up index in callee.
+func NewField(callee Expression, index Expression) *FieldExpression {
+	return &FieldExpression{
+		Callee: callee,
+		Index:  index,
+	}
 }
 
 func (f *FieldExpression) Line() int {
@@ -21,3 +30,4 @@ func (f *FieldExpression) expression() {}
 
 var _ Node = (*FieldExpression)(nil)
 var _ Expression = (*FieldExpression)(nil)
+var _ Spanned = (*FieldExpression)(nil)
@@ -1,13 +1,21 @@
 package ast
 
 // ForExpression ranges over a range of values, executing a block of statements for each iteration.
+// If ValueIdent is set, the current value must be a ranger.HashEntry or a ranger.Pair, and is destructured
+// into Ident (key) and ValueIdent (value), while StatusIdent, if set, receives the iteration status.
+// Otherwise, if StatusIdent is set and the current value is a ranger.Pair, the pair is destructured into Ident
+// and StatusIdent, and the iteration status is not available. If ElseBlock is set, it is executed instead
+// of Block if the range of values is empty.
 type ForExpression struct {
 	StartLine int
 	StartCol  int
 	Ident
+	ValueIdent  *Ident
 	StatusIdent *Ident
 	RangeExpr   Expression
 	Block
+	ElseBlock *Block
+	Span
 }
 
 func (f *ForExpression) Line() int {
@@ -22,3 +30,4 @@ func (f *ForExpression) expression() {}
 
 var _ Node = (*ForExpression)(nil)
 var _ Expression = (*ForExpression)(nil)
+var _ Spanned = (*ForExpression)(nil)
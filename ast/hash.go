@@ -5,6 +5,7 @@ type HashExpression struct {
 	StartLine int
 	StartCol  int
 	Values    map[string]Expression
+	Span
 }
 
 func (h *HashExpression) Line() int {
@@ -19,3 +20,4 @@ func (h *HashExpression) expression() {}
 
 var _ Node = (*HashExpression)(nil)
 var _ Expression = (*HashExpression)(nil)
+var _ Spanned = (*HashExpression)(nil)
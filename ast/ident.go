@@ -5,6 +5,14 @@ type Ident struct {
 	StartLine int
 	StartCol  int
 	Name      string
+	Span
+}
+
+// NewIdent returns a new identifier with the given name.
+func NewIdent(name string) *Ident {
+	return &Ident{
+		Name: name,
+	}
 }
 
 func (i *Ident) Line() int {
@@ -19,3 +27,4 @@ func (i *Ident) expression() {}
 
 var _ Node = (*Ident)(nil)
 var _ Expression = (*Ident)(nil)
+var _ Spanned = (*Ident)(nil)
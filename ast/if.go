@@ -5,6 +5,7 @@ type IfExpression struct {
 	StartLine    int
 	StartCol     int
 	Conditionals []ConditionalBlock
+	Span
 }
 
 // ConditionalBlock contains a block of statements to be executed if the condition is met (if any.)
@@ -37,3 +38,4 @@ var _ Node = (*IfExpression)(nil)
 var _ Expression = (*IfExpression)(nil)
 
 var _ Node = (*ConditionalBlock)(nil)
+var _ Spanned = (*IfExpression)(nil)
@@ -7,6 +7,16 @@ type InfixExpression struct {
 	Left      Expression
 	Operator  string
 	Right     Expression
+	Span
+}
+
+// NewInfix returns a new infix expression that applies operator op to left and right.
+func NewInfix(left Expression, op string, right Expression) *InfixExpression {
+	return &InfixExpression{
+		Left:     left,
+		Operator: op,
+		Right:    right,
+	}
 }
 
 func (i *InfixExpression) Line() int {
@@ -21,3 +31,4 @@ func (i *InfixExpression) expression() {}
 
 var _ Node = (*InfixExpression)(nil)
 var _ Expression = (*InfixExpression)(nil)
+var _ Spanned = (*InfixExpression)(nil)
@@ -5,6 +5,14 @@ type IntLiteral struct {
 	StartLine int
 	StartCol  int
 	Value     int64
+	Span
+}
+
+// NewIntLiteral returns a new literal signed integer value.
+func NewIntLiteral(v int64) *IntLiteral {
+	return &IntLiteral{
+		Value: v,
+	}
 }
 
 func (i *IntLiteral) Line() int {
@@ -19,3 +27,4 @@ func (i *IntLiteral) expression() {}
 
 var _ Node = (*IntLiteral)(nil)
 var _ Expression = (*IntLiteral)(nil)
+var _ Spanned = (*IntLiteral)(nil)
@@ -6,6 +6,15 @@ type LetStatement struct {
 	StartCol  int
 	Ident
 	Expression
+	Span
+}
+
+// NewLet returns a new statement that assigns the value of e to the identifier name.
+func NewLet(name string, e Expression) *LetStatement {
+	return &LetStatement{
+		Ident:      *NewIdent(name),
+		Expression: e,
+	}
 }
 
 func (l *LetStatement) Line() int {
@@ -23,3 +32,4 @@ func (l *LetStatement) statement() {}
 var _ Node = (*LetStatement)(nil)
 var _ Statement = (*LetStatement)(nil)
 var _ Expression = (*LetStatement)(nil)
+var _ Spanned = (*LetStatement)(nil)
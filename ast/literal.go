@@ -5,6 +5,14 @@ type Literal struct {
 	StartLine int
 	StartCol  int
 	Text      string
+	Span
+}
+
+// NewLiteral returns a new literal text.
+func NewLiteral(text string) *Literal {
+	return &Literal{
+		Text: text,
+	}
 }
 
 func (l *Literal) Line() int {
@@ -19,3 +27,4 @@ func (l *Literal) expression() {}
 
 var _ Node = (*Literal)(nil)
 var _ Expression = (*Literal)(nil)
+var _ Spanned = (*Literal)(nil)
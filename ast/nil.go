@@ -4,6 +4,12 @@ package ast
 type NilLiteral struct {
 	StartLine int
 	StartCol  int
+	Span
+}
+
+// NewNilLiteral returns a new literal nil value.
+func NewNilLiteral() *NilLiteral {
+	return &NilLiteral{}
 }
 
 func (n *NilLiteral) Line() int {
@@ -18,3 +24,4 @@ func (n *NilLiteral) expression() {}
 
 var _ Node = (*NilLiteral)(nil)
 var _ Expression = (*NilLiteral)(nil)
+var _ Spanned = (*NilLiteral)(nil)
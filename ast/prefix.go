@@ -1,11 +1,20 @@
 package ast
 
-// PrefixExpression is an expression that starts with an operator, such as "-15" or "!x" (where x is a bool.)
+// PrefixExpression is an expression that starts with an operator, such as "-15", "+x" or "!x" (where x is a bool.)
 type PrefixExpression struct {
 	StartLine int
 	StartCol  int
 	Operator  string
 	Expression
+	Span
+}
+
+// NewPrefix returns a new prefix expression that applies operator op to e.
+func NewPrefix(op string, e Expression) *PrefixExpression {
+	return &PrefixExpression{
+		Operator:   op,
+		Expression: e,
+	}
 }
 
 func (p *PrefixExpression) Line() int {
@@ -20,3 +29,4 @@ func (p *PrefixExpression) expression() {}
 
 var _ Node = (*PrefixExpression)(nil)
 var _ Expression = (*PrefixExpression)(nil)
+var _ Spanned = (*PrefixExpression)(nil)
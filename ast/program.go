@@ -7,6 +7,13 @@ type Program struct {
 	Statements []Statement
 }
 
+// NewProgram returns a new program containing statements.
+func NewProgram(statements ...Statement) *Program {
+	return &Program{
+		Statements: statements,
+	}
+}
+
 // Statement is a single statement to be executed, such as a "let" or "if" statement.
 type Statement interface {
 	Node
@@ -0,0 +1,27 @@
+package ast
+
+// Spanned is implemented by nodes that know their end position in addition to their start position.
+type Spanned interface {
+	Node
+
+	// End returns the position directly following the node's last token. It returns 0, 0 if the
+	// end position is unknown.
+	End() (line int, col int)
+}
+
+// Span holds the end position of a node. It is embedded into nodes to implement Spanned.
+type Span struct {
+	EndLine int
+	EndCol  int
+}
+
+// End returns the position directly following the node's last token.
+func (s *Span) End() (int, int) {
+	return s.EndLine, s.EndCol
+}
+
+// SetEnd sets the position directly following the node's last token.
+func (s *Span) SetEnd(line int, col int) {
+	s.EndLine = line
+	s.EndCol = col
+}
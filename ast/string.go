@@ -5,6 +5,14 @@ type StringLiteral struct {
 	StartLine int
 	StartCol  int
 	Value     string
+	Span
+}
+
+// NewStringLiteral returns a new literal string with value v (not including quotes.)
+func NewStringLiteral(v string) *StringLiteral {
+	return &StringLiteral{
+		Value: v,
+	}
 }
 
 func (s *StringLiteral) Line() int {
@@ -19,3 +27,4 @@ func (s *StringLiteral) expression() {}
 
 var _ Node = (*StringLiteral)(nil)
 var _ Expression = (*StringLiteral)(nil)
+var _ Spanned = (*StringLiteral)(nil)
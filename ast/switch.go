@@ -0,0 +1,35 @@
+package ast
+
+// SwitchExpression executes the statements in the first of its case blocks whose value matches the subject.
+type SwitchExpression struct {
+	StartLine int
+	StartCol  int
+	Subject   Expression
+	Cases     []CaseBlock
+	Span
+}
+
+// CaseBlock contains a block of statements to be executed if the subject of a SwitchExpression matches Value.
+// The subject is compared to Value using Operator, such as "==" or "<". If Value is nil, the case block
+// is the default ("else") block that matches any subject.
+type CaseBlock struct {
+	StartLine int
+	StartCol  int
+	Operator  string
+	Value     Expression
+	Block
+}
+
+func (s *SwitchExpression) Line() int {
+	return s.StartLine
+}
+
+func (s *SwitchExpression) Col() int {
+	return s.StartCol
+}
+
+func (s *SwitchExpression) expression() {}
+
+var _ Node = (*SwitchExpression)(nil)
+var _ Expression = (*SwitchExpression)(nil)
+var _ Spanned = (*SwitchExpression)(nil)
@@ -0,0 +1,27 @@
+package ast
+
+// WhitespaceStatement changes how the whitespace of literal text is handled for the remainder of the enclosing
+// block or template, such as "whitespace trim" or "whitespace keep". If Trim is true, leading and trailing
+// whitespace is removed from all subsequent literal text, until changed by another WhitespaceStatement or until
+// the end of the enclosing block.
+//
+// The change is applied by the parser while parsing subsequent literal text, so the statement itself does
+// nothing when it is evaluated.
+type WhitespaceStatement struct {
+	StartLine int
+	StartCol  int
+	Trim      bool
+}
+
+func (w *WhitespaceStatement) Line() int {
+	return w.StartLine
+}
+
+func (w *WhitespaceStatement) Col() int {
+	return w.StartCol
+}
+
+func (w *WhitespaceStatement) statement() {}
+
+var _ Node = (*WhitespaceStatement)(nil)
+var _ Statement = (*WhitespaceStatement)(nil)
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"reflect"
+
+	"github.com/blizzy78/copper/ast"
+)
+
+// evalAssignStatement assigns to a map entry, slice element, or struct field. Assignments are rejected if the
+// target is accessed directly through an identifier stored in a locked scope, such as data passed in by the host.
+// Only that binding is protected: the same value may still be modified through another identifier it has been
+// assigned to, or when it is returned by a function call.
+func (ev *Evaluator) evalAssignStatement(a ast.AssignStatement) error {
+	if root, ok := rootIdent(a.Target); ok && ev.scope.IsReadOnly(root.Name) {
+		return newEvalErrorf(a.Target.StartLine, a.Target.StartCol, "cannot assign into read-only host value: %s", root.Name)
+	}
+
+	callee, err := ev.eval(a.Target.Callee)
+	if err != nil {
+		return err
+	}
+
+	index, err := ev.eval(a.Target.Index)
+	if err != nil {
+		return err
+	}
+
+	o, err := ev.eval(a.Expression)
+	if err != nil {
+		return err
+	}
+
+	line := a.Target.StartLine
+	col := a.Target.StartCol
+
+	calleeValue := reflect.ValueOf(callee)
+	if callee == nil || (calleeValue.Kind() == reflect.Ptr && calleeValue.IsNil()) {
+		return newEvalErrorf(line, col, "cannot assign to field or element of nil object")
+	}
+
+	switch calleeValue.Kind() {
+	case reflect.Map:
+		return assignMapEntry(calleeValue, index, o, line, col)
+
+	case reflect.Slice:
+		i, err := ToInt64(index)
+		if err != nil {
+			return newEvalErrorf(line, col, "type of index expression in assignment to slice is not int: %T", index)
+		}
+		return assignSliceElement(calleeValue, i, o, line, col)
+
+	case reflect.Ptr:
+		if calleeValue.Elem().Kind() != reflect.Struct {
+			break
+		}
+
+		name, err := toString(index)
+		if err != nil {
+			return newEvalErrorf(line, col, "type of index expression in assignment to field is not string: %T", index)
+		}
+		return assignStructField(callee, calleeValue.Elem(), name, o, line, col)
+
+	case reflect.Struct:
+		return newEvalErrorf(line, col, "cannot assign to field of unaddressable object of type %T", callee)
+	}
+
+	return newEvalErrorf(line, col, "cannot assign to field or element of object of type %T", callee)
+}
+
+// rootIdent returns the identifier that the chain of field expressions e is based on. It returns false if the
+// chain is based on any other expression, such as a function call.
+func rootIdent(e ast.Expression) (*ast.Ident, bool) {
+	for {
+		switch ex := e.(type) {
+		case *ast.Ident:
+			return ex, true
+		case *ast.FieldExpression:
+			e = ex.Callee
+		default:
+			return nil, false
+		}
+	}
+}
+
+// assignMapEntry sets the entry identified by key in the map m to v, converting key and v to the map's key
+// and element types.
+func assignMapEntry(m reflect.Value, key interface{}, v interface{}, line int, col int) error {
+	keyValue, err := toArgument(key, m.Type().Key())
+	if err != nil {
+		return newEvalError(err, line, col)
+	}
+
+	vValue, err := toArgument(v, m.Type().Elem())
+	if err != nil {
+		return newEvalError(err, line, col)
+	}
+
+	m.SetMapIndex(keyValue, vValue)
+	return nil
+}
+
+// assignSliceElement sets the element at index i of the slice s to v, converting v to the slice's element type.
+func assignSliceElement(s reflect.Value, i int64, v interface{}, line int, col int) error {
+	if i < 0 || i >= int64(s.Len()) {
+		return newEvalErrorf(line, col, "index out of range in object of type %s with length %d: %d", s.Type(), s.Len(), i)
+	}
+
+	vValue, err := toArgument(v, s.Type().Elem())
+	if err != nil {
+		return newEvalError(err, line, col)
+	}
+
+	s.Index(int(i)).Set(vValue)
+	return nil
+}
+
+// assignStructField sets the exported field identified by name of the addressable struct sValue to v,
+// converting v to the field's type.
+func assignStructField(s interface{}, sValue reflect.Value, name string, v interface{}, line int, col int) error {
+	if isUnexportedField(sValue, name) {
+		return newEvalErrorf(line, col, "field in object of type %T is not exported: %s", s, name)
+	}
+
+	f := sValue.FieldByName(name)
+	if !f.IsValid() {
+		return newEvalErrorf(line, col, "field not found in object of type %T: %s", s, name)
+	}
+
+	vValue, err := toArgument(v, f.Type())
+	if err != nil {
+		return newEvalError(err, line, col)
+	}
+
+	f.Set(vValue)
+	return nil
+}
@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/blizzy78/copper/ast"
+)
+
+// builtinFunc is a function that is always available in templates, unless its name is shadowed
+// by a value in the scope.
+type builtinFunc func(args []interface{}, line int, col int) (interface{}, error)
+
+var builtins = map[string]builtinFunc{
+	"len": builtinLen,
+}
+
+// builtin returns the builtin function called by c, if any. Builtin functions can only be called
+// directly by their name, and only if the name is not shadowed by a value in the scope.
+func (ev *Evaluator) builtin(c ast.CallExpression) (builtinFunc, bool) {
+	ident, ok := c.Callee.(*ast.Ident)
+	if !ok || ev.scope.HasValue(ident.Name) {
+		return nil, false
+	}
+
+	b, ok := builtins[ident.Name]
+	return b, ok
+}
+
+func (ev *Evaluator) evalBuiltinCall(b builtinFunc, c ast.CallExpression) (interface{}, error) {
+	args := make([]interface{}, len(c.Params))
+	for i, e := range c.Params {
+		a, err := ev.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = a
+	}
+
+	return b(args, c.StartLine, c.StartCol)
+}
+
+// builtinLen returns the length of a string, slice, array, or map. The length of a string is the number
+// of runes (not bytes) in it.
+func builtinLen(args []interface{}, line int, col int) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, newEvalErrorf(line, col, "wrong number of arguments for function call to len: expected 1, got %d", len(args))
+	}
+
+	v := args[0]
+	if v == nil {
+		return nil, newEvalErrorf(line, col, "cannot get length of nil")
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.String:
+		return int64(utf8.RuneCountInString(value.String())), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(value.Len()), nil
+	default:
+		return nil, newEvalErrorf(line, col, "cannot get length of unsupported type: %T", v)
+	}
+}
@@ -3,11 +3,13 @@ package evaluator
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 )
 
-// toInt64 converts v to an int64. v may be any of int, int8, int16, int32, int64, or a type derived from those.
-func toInt64(v interface{}) (int64, error) {
+// ToInt64 converts v to an int64. v may be any of int, int8, int16, int32, int64, uint, uint8, uint16, uint32,
+// uint64, or a type derived from those. ToInt64 returns an error if v is an unsigned integer too large for int64.
+func ToInt64(v interface{}) (int64, error) {
 	if v == nil {
 		return 0, errors.New("cannot convert nil to int64")
 	}
@@ -16,11 +18,27 @@ func toInt64(v interface{}) (int64, error) {
 	switch value.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("integer overflow converting to int64: %d", value.Uint())
+		}
+		return int64(value.Uint()), nil
 	default:
 		return 0, fmt.Errorf("cannot convert unsupported type to int64: %T", v)
 	}
 }
 
+// isIntKind returns whether k is the kind of a signed or unsigned integer type.
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
 // toString converts v to a string. v may be a string or a type derived from it.
 func toString(v interface{}) (string, error) {
 	if v == nil {
@@ -98,3 +116,59 @@ func toBool(v interface{}) (bool, error) {
 
 	return value.Bool(), nil
 }
+
+// toArgument converts v to a value of type t, suitable to be passed as an argument to a method or function.
+// If v is nil, the zero value of t is returned. If v is a map indexed by strings and t is a struct type,
+// a new struct is returned with its exported fields populated from the map's entries (see toStruct.)
+// If t is Optional, v is wrapped in a valid Optional.
+func toArgument(v interface{}, t reflect.Type) (reflect.Value, error) {
+	if t == optionalType {
+		return reflect.ValueOf(Optional{
+			Value: v,
+			Valid: true,
+		}), nil
+	}
+
+	if v == nil {
+		return reflect.New(t).Elem(), nil
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Type().ConvertibleTo(t) {
+		return value.Convert(t), nil
+	}
+
+	if value.Kind() == reflect.Map && t.Kind() == reflect.Struct {
+		m, err := toMap(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return toStruct(m, t)
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert argument of type %T to required type %s", v, t)
+}
+
+// toStruct returns a new struct of type t whose exported fields are populated from the entries of m,
+// matching keys to field names. Fields that have no entry in m are left at their zero values.
+// toStruct returns an error if m contains a key that does not name an exported field of t.
+func toStruct(m map[string]interface{}, t reflect.Type) (reflect.Value, error) {
+	s := reflect.New(t).Elem()
+
+	for k, v := range m {
+		f, ok := t.FieldByName(k)
+		if !ok || f.PkgPath != "" {
+			return reflect.Value{}, fmt.Errorf("no exported field in struct of type %s: %s", t, k)
+		}
+
+		fValue, err := toArgument(v, f.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot set field %s in struct of type %s: %w", k, t, err)
+		}
+
+		s.FieldByIndex(f.Index).Set(fValue)
+	}
+
+	return s, nil
+}
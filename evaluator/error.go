@@ -50,3 +50,7 @@ func (e evalError) Error() string {
 	}
 	return fmt.Sprintf("evaluation error at line %d, column %d: %v", e.line, e.col, e.err)
 }
+
+func (e evalError) Unwrap() error {
+	return e.err
+}
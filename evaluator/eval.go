@@ -1,8 +1,10 @@
 package evaluator
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/scope"
@@ -12,6 +14,14 @@ import (
 type Evaluator struct {
 	literalStringer   LiteralStringer
 	argumentResolvers []ArgumentResolver
+	operatorFuncs     map[string][]OperatorFunc
+	callDepth         int
+	maxCallDepth      int
+	loopTimeout       time.Duration
+	blocks            map[string]ast.Block
+	strictVars        bool
+	euclideanMod      bool
+	stringCoercion    bool
 	scope             *scope.Scope
 	loopLevel         int
 	breakRequested    bool
@@ -45,11 +55,33 @@ type ArgumentResolver interface {
 // If f is a function with the appropriate signature, ArgumentResolverFunc(f) is an argument resolver that calls f.
 type ArgumentResolverFunc func(t reflect.Type) (interface{}, error)
 
+// An OperatorFunc evaluates an infix expression for the operands l and r, such as "l + r", and returns its result.
+// If it cannot handle the operands, it must return ErrOperatorNotHandled.
+type OperatorFunc func(l interface{}, r interface{}) (interface{}, error)
+
+// ErrOperatorNotHandled is returned by an OperatorFunc if it cannot handle an infix expression's operands.
+var ErrOperatorNotHandled = errors.New("operator not handled")
+
+// CallDepth is the depth of nested method or function calls, starting at 1 for the outermost call.
+// Arguments of type CallDepth are resolved automatically with the depth of the call they are passed to.
+// Functions that evaluate templates using another evaluator may use WithCallDepth to pass the depth on.
+type CallDepth int
+
+// Optional is the type of an optional function parameter. If a function call omits the argument for a parameter
+// of type Optional, the zero value is passed, and Valid is false. If the argument is supplied, Value is set to it,
+// and Valid is true. Because arguments can only be omitted from the end, only trailing parameters should be of
+// type Optional.
+type Optional struct {
+	Value interface{}
+	Valid bool
+}
+
 // New returns a new evaluator, configured with opts.
 func New(opts ...Opt) *Evaluator {
 	ev := &Evaluator{
 		literalStringer:   LiteralStringerFunc(defaultLiteral),
 		argumentResolvers: []ArgumentResolver{ArgumentResolverFunc(defaultResolve)},
+		strictVars:        true,
 	}
 
 	for _, opt := range opts {
@@ -78,6 +110,88 @@ func WithArgumentResolver(r ArgumentResolver) Opt {
 	}
 }
 
+// WithOperatorFunc configures an evaluator to use fn to evaluate infix expressions using the operator op,
+// such as "+" or "==". Operator functions are consulted before the builtin operators, so they can be used
+// to handle custom types. If fn returns ErrOperatorNotHandled, the builtin operator is used instead.
+//
+// WithOperatorFunc may be used multiple times to configure additional operator functions, also for the
+// same operator. The first operator function to handle the operands wins.
+func WithOperatorFunc(op string, fn OperatorFunc) Opt {
+	return func(ev *Evaluator) {
+		if ev.operatorFuncs == nil {
+			ev.operatorFuncs = map[string][]OperatorFunc{}
+		}
+		ev.operatorFuncs[op] = append(ev.operatorFuncs[op], fn)
+	}
+}
+
+// WithMaxCallDepth configures an evaluator to stop with an error if the depth of nested method or function
+// calls would exceed n. This can be used to prevent infinite recursion, for example when templates include
+// themselves. The default is to not limit the call depth.
+func WithMaxCallDepth(n int) Opt {
+	return func(ev *Evaluator) {
+		ev.maxCallDepth = n
+	}
+}
+
+// WithLoopTimeout configures an evaluator to stop with an error if a single for loop runs for longer than d.
+// The elapsed time is checked before each iteration of the loop, so a single iteration is never interrupted.
+// The default is to not limit the time of loops.
+func WithLoopTimeout(d time.Duration) Opt {
+	return func(ev *Evaluator) {
+		ev.loopTimeout = d
+	}
+}
+
+// WithStrictVars configures whether an evaluator stops with an error when an identifier cannot be found in the
+// scope, or when a key cannot be found in a map accessed by a field expression. If strict is false, the identifier
+// or map entry evaluates to nil instead. The default is to stop with an error.
+func WithStrictVars(strict bool) Opt {
+	return func(ev *Evaluator) {
+		ev.strictVars = strict
+	}
+}
+
+// WithEuclideanMod configures an evaluator to compute the '%' operator as the Euclidean modulo, whose result
+// is never negative, such as "-1 % 3 == 2". The default is to use Go semantics, where the result has the sign
+// of the dividend, such as "-1 % 3 == -1".
+func WithEuclideanMod() Opt {
+	return func(ev *Evaluator) {
+		ev.euclideanMod = true
+	}
+}
+
+// WithStringCoercion configures an evaluator to convert integers and bools to their string forms in '+' infix
+// expressions where the other operand is a string, such as `"count: " + 5`. The default is to stop with an error,
+// so that mistakes are not masked.
+func WithStringCoercion() Opt {
+	return func(ev *Evaluator) {
+		ev.stringCoercion = true
+	}
+}
+
+// WithBlocks configures an evaluator to execute the blocks of blocks instead of the blocks of block expressions
+// with the same names (see ast.BlockExpression.) This is used to render templates that extend other templates.
+// WithBlocks may be used multiple times, adding to the existing blocks.
+func WithBlocks(blocks map[string]ast.Block) Opt {
+	return func(ev *Evaluator) {
+		if ev.blocks == nil {
+			ev.blocks = map[string]ast.Block{}
+		}
+		for name, b := range blocks {
+			ev.blocks[name] = b
+		}
+	}
+}
+
+// WithCallDepth configures an evaluator to start at call depth d instead of 0. This is useful when the evaluator
+// is used by a method or function that has been called by another evaluator (see CallDepth.)
+func WithCallDepth(d CallDepth) Opt {
+	return func(ev *Evaluator) {
+		ev.callDepth = int(d)
+	}
+}
+
 // Eval evaluates the abstract syntax tree node n and returns its result. The scope s is used to look up and store
 // variable state using identifiers. The scope may be pre-filled with identifiers which can be used during evaluation
 // of expressions.
@@ -133,7 +247,14 @@ func normalize(v interface{}) interface{} { //nolint:gocyclo
 	case uint64:
 		return int64(value)
 
+	case nil:
+		return nil
+
 	default:
+		// fold typed nil pointers to untyped nil
+		if vValue := reflect.ValueOf(v); vValue.Kind() == reflect.Ptr && vValue.IsNil() {
+			return nil
+		}
 		return v
 	}
 }
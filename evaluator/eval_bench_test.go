@@ -13,6 +13,11 @@ import (
 )
 
 func benchmarkEvaluator(tmpl string, b *testing.B) {
+	b.Helper()
+	benchmarkEvaluatorFold(tmpl, false, b)
+}
+
+func benchmarkEvaluatorFold(tmpl string, fold bool, b *testing.B) {
 	b.Helper()
 	b.StopTimer()
 
@@ -35,6 +40,10 @@ func benchmarkEvaluator(tmpl string, b *testing.B) {
 
 	e := New()
 
+	if fold {
+		prog = e.Fold(prog)
+	}
+
 	b.StartTimer()
 
 	for i := 0; i < b.N; i++ {
@@ -76,3 +85,19 @@ func BenchmarkEvaluatorIncrement10(b *testing.B) {
 func BenchmarkEvaluatorIncrement100(b *testing.B) {
 	benchmarkEvaluatorIncrement(100, b)
 }
+
+const constantExpressionTemplate = `
+let s = ""
+for i in fromTo(1, 100)
+	let s = "a" + "b" + intToString(1 + 2 * 3 - 4 / 2 + i)
+end
+s
+`
+
+func BenchmarkEvaluatorConstantExpression(b *testing.B) {
+	benchmarkEvaluatorFold(constantExpressionTemplate, false, b)
+}
+
+func BenchmarkEvaluatorConstantExpressionFolded(b *testing.B) {
+	benchmarkEvaluatorFold(constantExpressionTemplate, true, b)
+}
@@ -2,7 +2,13 @@ package evaluator
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/lexer"
@@ -11,6 +17,8 @@ import (
 	"github.com/blizzy78/copper/scope"
 )
 
+var errMock = errors.New("mock error")
+
 type MockObject struct {
 	Field        int
 	MockFieldPtr *MockObject
@@ -21,6 +29,34 @@ type MockObject2 struct {
 	Field int
 }
 
+type MockUnexported struct {
+	Exported   int
+	unexported int
+}
+
+type MockStatus string
+
+type MockOtherStatus string
+
+type MockLevel int
+
+type MockUnsignedLevel uint8
+
+type MockVersion struct {
+	Major int
+	Minor int
+}
+
+type MockOptions struct {
+	Width  int
+	Height int
+	Title  string
+}
+
+func (v MockVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
 func (m *MockObject) Five() int {
 	return 5
 }
@@ -39,6 +75,38 @@ func (m *MockObject) SumWithMap(mp map[string]interface{}) int {
 	return int(x + y)
 }
 
+func TestEval_Constructors(t *testing.T) {
+	// let x = 2 * -3
+	// x + double(a.Field) == 0
+	prog := ast.NewProgram(
+		ast.NewLet("x", ast.NewInfix(ast.NewIntLiteral(2), "*", ast.NewPrefix("-", ast.NewIntLiteral(3)))),
+		ast.NewExpressionStatement(
+			ast.NewInfix(
+				ast.NewInfix(
+					ast.NewIdent("x"),
+					"+",
+					ast.NewCall(ast.NewIdent("double"), ast.NewField(ast.NewIdent("a"), ast.NewStringLiteral("Field"))),
+				),
+				"==",
+				ast.NewIntLiteral(0),
+			),
+		),
+	)
+
+	s := scope.Scope{}
+	s.Set("a", MockObject{Field: 3})
+	s.Set("double", func(i int) int {
+		return i * 2
+	})
+
+	o, err := New().Eval(prog, &s)
+	if err != nil {
+		t.Fatalf("error evaluating program: %v", err)
+	}
+
+	testObject(0, o, true, t)
+}
+
 func TestEvalIntExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -51,6 +119,18 @@ func TestEvalIntExpression(t *testing.T) {
 		{"-5", -5},
 		{"-12", -12},
 		{"-1234", -1234},
+		{"+5", 5},
+		{"+-5", -5},
+		{"-+5", -5},
+		{"3 - +2", 1},
+		{"- -5", 5},
+		{"--5", 5},
+		{"-(-5)", 5},
+		{"2 - -1", 3},
+		{"-2 - -1", -1},
+		{"-2 * -3", 6},
+		{"-9223372036854775808", math.MinInt64},
+		{"-9223372036854775807 - 1", math.MinInt64},
 		{"1 + 2 * 3", 7},
 		{"1 + (2 * 3)", 7},
 		{"(1 + 2) * 3", 9},
@@ -65,6 +145,78 @@ func TestEvalIntExpression(t *testing.T) {
 	}
 }
 
+func TestInfixExpression_NamedTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`status == "active"`, true},
+		{`status != "active"`, false},
+		{`"active" == status`, true},
+		{`status == "inactive"`, false},
+		{`status == otherStatus`, true},
+		{`level == 3`, true},
+		{`level != 3`, false},
+		{`level > 2`, true},
+		{`level == unsignedLevel`, true},
+		{`unsignedLevel < 4`, true},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("status", MockStatus("active"))
+		s.Set("otherStatus", MockOtherStatus("active"))
+		s.Set("level", MockLevel(3))
+		s.Set("unsignedLevel", MockUnsignedLevel(3))
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	tests := []struct {
+		v        interface{}
+		expected int64
+	}{
+		{5, 5},
+		{int8(-5), -5},
+		{MockUnsignedLevel(3), 3},
+		{uint64(math.MaxInt64), math.MaxInt64},
+	}
+
+	for i, test := range tests {
+		n, err := ToInt64(test.v)
+		if err != nil {
+			t.Fatalf("[%d] error converting: %v", i, err)
+		}
+		testObject(i, n, test.expected, t)
+	}
+
+	if _, err := ToInt64(uint64(math.MaxInt64) + 1); err == nil || err.Error() != "integer overflow converting to int64: 9223372036854775808" {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestPrefixExpression_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`-"a"`, "line 1, column 1: incompatible expression type for '-' prefix expression: string"},
+		{`+"a"`, "line 1, column 1: incompatible expression type for '+' prefix expression: string"},
+		{`+true`, "line 1, column 1: incompatible expression type for '+' prefix expression: bool"},
+		{`!5`, "line 1, column 1: incompatible expression type for '!' prefix expression: int64"},
+	}
+
+	for i, test := range tests {
+		err := evalWithScopeError(i, test.input, &scope.Scope{}, t, lexer.WithStartInCodeMode())
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
 func TestEvalBoolExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -102,6 +254,10 @@ func TestEvalBoolExpression(t *testing.T) {
 		{`"x" == "y"`, false},
 		{`"x" != "x"`, false},
 		{`"x" != "y"`, true},
+		{"nil == nil", true},
+		{"nil != nil", false},
+		{"5 == nil", false},
+		{`nil != "x"`, true},
 	}
 
 	for i, test := range tests {
@@ -189,6 +345,302 @@ func TestEvalIfExpression(t *testing.T) {
 	}
 }
 
+func TestInfixExpression_OperatorFunc(t *testing.T) {
+	type money struct {
+		cents int64
+	}
+
+	addMoney := func(l interface{}, r interface{}) (interface{}, error) {
+		lm, lok := l.(money)
+		rm, rok := r.(money)
+		switch {
+		case lok && rok:
+			return money{lm.cents + rm.cents}, nil
+		case lok || rok:
+			return nil, errMock
+		default:
+			return nil, ErrOperatorNotHandled
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"a + b", money{350}},
+		{"a + b + a", money{450}},
+		{"1 + 2", int64(3)},
+		{`"x" + "y"`, "xy"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("a", money{100})
+		s.Set("b", money{250})
+
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+
+		o, err := New(WithOperatorFunc("+", addMoney)).Eval(prog, &s)
+		if err != nil {
+			t.Fatalf("[%d] error evaluating expression: %v", i, err)
+		}
+
+		if o != test.expected {
+			t.Fatalf("[%d] wrong value, expected=%v, got=%v", i, test.expected, o)
+		}
+	}
+
+	s := scope.Scope{}
+	s.Set("a", money{100})
+
+	prog := parse(0, "a + 1", t, lexer.WithStartInCodeMode())
+
+	_, err := New(WithOperatorFunc("+", addMoney)).Eval(prog, &s)
+	if !errors.Is(err, errMock) || !IsEvaluationError(err) {
+		t.Fatalf("wrong error, expected=%v, got=%v", errMock, err)
+	}
+}
+
+func TestStrictVars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`h.missing`, "line 1, column 1: key not found in map: missing"},
+		{`h["missing"]`, "line 1, column 1: key not found in map: missing"},
+		{`missing`, "line 1, column 1: identifier not found in scope: missing"},
+	}
+
+	for i, test := range tests {
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+
+		s := scope.Scope{}
+		s.Set("h", map[string]interface{}{"x": 1})
+
+		// strict by default
+		for _, ev := range []*Evaluator{New(), New(WithStrictVars(true))} {
+			_, err := ev.Eval(prog, &s)
+			if err == nil || !strings.HasSuffix(err.Error(), test.expected) {
+				t.Fatalf("[%d] wrong error: %v", i, err)
+			}
+		}
+
+		o, err := New(WithStrictVars(false)).Eval(prog, &s)
+		if err != nil {
+			t.Fatalf("[%d] error evaluating expression: %v", i, err)
+		}
+		testObject(i, o, nil, t)
+	}
+}
+
+func TestMembersOf(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected []string
+	}{
+		{&MockObject{}, []string{"Double", "Field", "Five", "MockField", "MockFieldPtr", "Sum", "SumWithMap"}},
+		{MockObject{}, []string{"Field", "MockField", "MockFieldPtr"}}, // methods have pointer receivers
+		{MockUnexported{}, []string{"Exported"}},
+		{map[string]interface{}{"b": 1, "a": 2}, []string{"a", "b"}},
+		{(*MockObject)(nil), []string{}},
+		{nil, []string{}},
+		{123, []string{}},
+	}
+
+	for i, test := range tests {
+		actual := MembersOf(test.input)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Fatalf("[%d] wrong members, expected=%v, got=%v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestAssignStatement(t *testing.T) {
+	type user struct {
+		Name  string
+		Level MockLevel
+	}
+
+	m := map[string]interface{}{"a": 1}
+	sl := []int{1, 2, 3}
+	u := &user{Name: "foo"}
+
+	s := scope.Scope{}
+	s.Set("m", m)
+	s.Set("sl", sl)
+	s.Set("u", u)
+
+	evalWithScope(0, `let m.a = 10
+		let m["b"] = m.a + 1
+		let sl[1] = 20
+		let u.Name = "bar"
+		let u.Level = 3`, &s, t, lexer.WithStartInCodeMode())
+
+	testObject(0, m["a"], 10, t)
+	testObject(0, m["b"], 11, t)
+	testObject(0, sl[1], 20, t)
+	testObject(0, u.Name, "bar", t)
+	testObject(0, int(u.Level), 3, t)
+}
+
+func TestAssignStatement_WithoutLet(t *testing.T) {
+	m := map[string]interface{}{"a": 1}
+
+	s := scope.Scope{}
+	s.Set("m", m)
+
+	evalWithScope(0, `m.a = 10
+		m["b"] = m.a + 1
+		m["c"] = {}
+		m.c.d = "x"`, &s, t, lexer.WithStartInCodeMode())
+
+	testObject(0, m["a"], 10, t)
+	testObject(0, m["b"], 11, t)
+	testObject(0, m["c"].(map[string]interface{})["d"], "x", t)
+}
+
+func TestAssignStatement_WithoutLet_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`n["x"] = 1`, "line 1, column 1: cannot assign to field or element of nil object"},
+		{`i.x = 1`, "line 1, column 1: cannot assign to field or element of object of type int64"},
+		{`s["x"] = 1`, "line 1, column 1: cannot assign to field or element of object of type string"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("n", nil)
+		s.Set("i", 1)
+		s.Set("s", "foo")
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.HasSuffix(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestAssignStatement_Error(t *testing.T) {
+	type user struct {
+		Name string
+		role string
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let u.Name = "x"`, "line 1, column 5: cannot assign to field of unaddressable object of type evaluator.user"},
+		{`let up.role = "x"`, "line 1, column 5: field in object of type *evaluator.user is not exported: role"},
+		{`let up.Foo = "x"`, "line 1, column 5: field not found in object of type *evaluator.user: Foo"},
+		{`let up.Name = true`, "line 1, column 5: cannot convert argument of type bool to required type string"},
+		{`let sl[3] = 1`, "line 1, column 5: index out of range in object of type []int with length 3: 3"},
+		{`let n.x = 1`, "line 1, column 5: cannot assign to field or element of nil object"},
+		{`let i.x = 1`, "line 1, column 5: cannot assign to field or element of object of type int64"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("u", user{})
+		s.Set("up", &user{})
+		s.Set("sl", []int{1, 2, 3})
+		s.Set("n", (*user)(nil))
+		s.Set("i", 1)
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.HasSuffix(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestAssignStatement_ReadOnly(t *testing.T) {
+	type user struct {
+		Name string
+	}
+
+	tests := []string{
+		`let m.a = 2`,
+		`m["a"] = 2`,
+		`let sl[0] = 2`,
+		`u.Name = "bar"`,
+		`let m.b.c = 2`,
+	}
+
+	for i, input := range tests {
+		m := map[string]interface{}{"a": 1, "b": map[string]interface{}{}}
+		sl := []int{1}
+		u := &user{Name: "foo"}
+
+		data := scope.Scope{}
+		data.Set("m", m)
+		data.Set("sl", sl)
+		data.Set("u", u)
+		data.Lock()
+
+		s := scope.Scope{
+			Parent: &data,
+		}
+
+		err := evalWithScopeError(i, input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.Contains(err.Error(), "cannot assign into read-only host value: ") {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+
+		testObject(i, m["a"], 1, t)
+		testObject(i, len(m["b"].(map[string]interface{})), 0, t)
+		testObject(i, sl[0], 1, t)
+		testObject(i, u.Name, "foo", t)
+	}
+}
+
+func TestAssignStatement_ReadOnly_Binding(t *testing.T) {
+	tests := []string{
+		`let m2 = m
+		m2["a"] = 2`,
+		`getMap().a = 2`,
+	}
+
+	for i, input := range tests {
+		m := map[string]interface{}{"a": 1}
+
+		data := scope.Scope{}
+		data.Set("m", m)
+		data.Set("getMap", func() map[string]interface{} {
+			return m
+		})
+		data.Lock()
+
+		s := scope.Scope{
+			Parent: &data,
+		}
+
+		// only the read-only binding is protected, not the value itself
+		evalWithScope(i, input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, m["a"], 2, t)
+	}
+}
+
+func TestLetStatement_ReadOnly(t *testing.T) {
+	data := scope.Scope{}
+	data.Set("x", 5)
+	data.Lock()
+
+	s := scope.Scope{
+		Parent: &data,
+	}
+
+	err := evalWithScopeError(0, "let x = 6", &s, t, lexer.WithStartInCodeMode())
+	if !strings.Contains(err.Error(), "line 1, column 1: cannot assign to read-only identifier: x") {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	v, _ := s.Value("x")
+	testObject(0, v, 5, t)
+}
+
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -220,86 +672,331 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
-func TestIdentExpression(t *testing.T) {
+func TestCompoundAssign(t *testing.T) {
 	tests := []struct {
-		input       string
-		scopeValues map[string]interface{}
-		expected    interface{}
+		input    string
+		expected interface{}
 	}{
-		{
-			`x`,
-			map[string]interface{}{
-				"x": 5,
-			},
-			5,
-		},
-		{
-			`x + y`,
-			map[string]interface{}{
-				"x": 5,
-				"y": 6,
-			},
-			11,
-		},
-		{
-			`x + y`,
-			map[string]interface{}{
-				"x": "a",
-				"y": "b",
-			},
-			"ab",
-		},
-		{
-			`let x = 5
-			let y = 6
-			x + y`,
-			map[string]interface{}{},
-			11,
-		},
-		{
-			`x && y`,
-			map[string]interface{}{
-				"x": false,
-			},
-			false,
-		},
-		{
-			`x || y`,
-			map[string]interface{}{
-				"x": true,
-			},
-			true,
-		},
+		{`let x = 5 x += 3`, 8},
+		{`let x = 5 x -= 3`, 2},
+		{`let x = 5 x *= 3`, 15},
+		{`let x = 15 x /= 3`, 5},
+		{`let x = 2 x *= 3 + 4`, 14},
+		{`let x = "foo" x += "bar"`, "foobar"},
+		{`let x = 0 for i in range(1, 5) x += i end`, 10},
 	}
 
 	for i, test := range tests {
 		s := scope.Scope{}
 
-		for k, v := range test.scopeValues {
-			s.Set(k, v)
+		s.Set("range", ranger.NewInt)
+
+		evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testScopeValue(i, &s, "x", test.expected, t)
+	}
+}
+
+func TestCompoundAssign_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x += 1`, "evaluation error at line 1, column 1: identifier not found in scope: x"},
+		{`y += 1`, "evaluation error at line 1, column 1: cannot assign to read-only identifier: y"},
+	}
+
+	for i, test := range tests {
+		data := scope.Scope{}
+		data.Set("y", 5)
+		data.Lock()
+
+		s := scope.Scope{
+			Parent: &data,
 		}
 
-		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
-		testObject(i, o, test.expected, t)
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if err.Error() != test.expected {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
 	}
 }
 
-func TestFieldExpression(t *testing.T) {
+func TestBoolOrdering_Error(t *testing.T) {
 	tests := []struct {
-		input       string
-		scopeValues map[string]interface{}
-		expected    interface{}
+		input    string
+		expected string
 	}{
-		{
-			"x.y",
-			map[string]interface{}{
-				"x": map[string]interface{}{
-					"y": 5,
-				},
-			},
-			5,
-		},
-		{
+		{`true < false`, "evaluation error at line 1, column 1: booleans cannot be ordered: <"},
+		{`let x = 1
+		x > 0 >= true`, "evaluation error at line 2, column 3: booleans cannot be ordered: >="},
+		{`false <= x`, "evaluation error at line 1, column 1: booleans cannot be ordered: <="},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("x", true)
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if err.Error() != test.expected {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestInfixExpression_ShortCircuit(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      interface{}
+		expectedError string
+	}{
+		{`false && f()`, false, ""},
+		{`true || f()`, true, ""},
+		{`!true && f() && f()`, false, ""},
+		{`1 && f()`, nil, "evaluation error at line 1, column 1: left operand of '&&' infix expression is not bool: int64"},
+		{`n || f()`, nil, "evaluation error at line 1, column 1: left operand of '||' infix expression is not bool: <nil>"},
+		{`"a" && fail()`, nil, "evaluation error at line 1, column 1: left operand of '&&' infix expression is not bool: string"},
+	}
+
+	for i, test := range tests {
+		called := false
+
+		s := scope.Scope{}
+		s.Set("f", func() bool {
+			called = true
+			return true
+		})
+		s.Set("fail", func() error {
+			return errMock
+		})
+		s.Set("n", nil)
+
+		if test.expectedError != "" {
+			err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+			if err.Error() != test.expectedError {
+				t.Fatalf("[%d] wrong error: %v", i, err)
+			}
+		} else {
+			o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+			testObject(i, o, test.expected, t)
+		}
+
+		if called {
+			t.Fatalf("[%d] right operand was evaluated", i)
+		}
+	}
+}
+
+func TestInfixExpression_Mod(t *testing.T) {
+	tests := []struct {
+		input             string
+		expected          int64
+		expectedEuclidean int64
+	}{
+		{`7 % 3`, 1, 1},
+		{`-7 % 3`, -1, 2},
+		{`7 % -3`, 1, 1},
+		{`-7 % -3`, -1, 2},
+		{`-6 % 3`, 0, 0},
+		{`0 % -3`, 0, 0},
+	}
+
+	for i, test := range tests {
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+
+		o, err := New().Eval(prog, &scope.Scope{})
+		if err != nil {
+			t.Fatalf("[%d] error evaluating expression: %v", i, err)
+		}
+
+		testObject(i, o, test.expected, t)
+
+		o, err = New(WithEuclideanMod()).Eval(prog, &scope.Scope{})
+		if err != nil {
+			t.Fatalf("[%d] error evaluating expression: %v", i, err)
+		}
+
+		testObject(i, o, test.expectedEuclidean, t)
+	}
+}
+
+func TestInfixExpression_Mod_DivisionByZero(t *testing.T) {
+	prog := parse(0, `-1 % 0`, t, lexer.WithStartInCodeMode())
+
+	for _, ev := range []*Evaluator{New(), New(WithEuclideanMod())} {
+		_, err := ev.Eval(prog, &scope.Scope{})
+		if err == nil || err.Error() != "evaluation error at line 1, column 1: division by zero" {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestInfixExpression_Stringer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"version " + v`, "version 1.2"},
+		{`v + " is current"`, "1.2 is current"},
+		{`"v" + v + "-" + v`, "v1.2-1.2"},
+		{`status + v`, "active1.2"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("v", MockVersion{1, 2})
+		s.Set("status", MockStatus("active"))
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestInfixExpression_Stringer_Error(t *testing.T) {
+	tests := []string{
+		`v + v`,
+		`v + 1`,
+		`v - "a"`,
+		`v == "1.2"`,
+	}
+
+	for i, input := range tests {
+		s := scope.Scope{}
+		s.Set("v", MockVersion{1, 2})
+
+		evalWithScopeError(i, input, &s, t, lexer.WithStartInCodeMode())
+	}
+}
+
+func TestInfixExpression_StringCoercion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"count: " + 5`, "count: 5"},
+		{`5 + " items"`, "5 items"},
+		{`"n=" + -3 + ", ok=" + true`, "n=-3, ok=true"},
+		{`"level " + level`, "level 3"},
+		{`"version " + v`, "version 1.2"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("level", MockLevel(3))
+		s.Set("v", MockVersion{1, 2})
+
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+
+		o, err := New(WithStringCoercion()).Eval(prog, &s)
+		if err != nil {
+			t.Fatalf("[%d] error evaluating expression: %v", i, err)
+		}
+
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestInfixExpression_StringCoercion_Error(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []Opt
+	}{
+		{`"count: " + 5`, nil},
+		{`5 + "items"`, nil},
+		{`"ok: " + true`, nil},
+		{`"a" - 5`, []Opt{WithStringCoercion()}},
+		{`"a" + nil`, []Opt{WithStringCoercion()}},
+	}
+
+	for i, test := range tests {
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+
+		_, err := New(test.opts...).Eval(prog, &scope.Scope{})
+		if err == nil {
+			t.Fatalf("[%d] expected error evaluating expression", i)
+		}
+	}
+}
+
+func TestIdentExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		scopeValues map[string]interface{}
+		expected    interface{}
+	}{
+		{
+			`x`,
+			map[string]interface{}{
+				"x": 5,
+			},
+			5,
+		},
+		{
+			`x + y`,
+			map[string]interface{}{
+				"x": 5,
+				"y": 6,
+			},
+			11,
+		},
+		{
+			`x + y`,
+			map[string]interface{}{
+				"x": "a",
+				"y": "b",
+			},
+			"ab",
+		},
+		{
+			`let x = 5
+			let y = 6
+			x + y`,
+			map[string]interface{}{},
+			11,
+		},
+		{
+			`x && y`,
+			map[string]interface{}{
+				"x": false,
+			},
+			false,
+		},
+		{
+			`x || y`,
+			map[string]interface{}{
+				"x": true,
+			},
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		for k, v := range test.scopeValues {
+			s.Set(k, v)
+		}
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestFieldExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		scopeValues map[string]interface{}
+		expected    interface{}
+	}{
+		{
+			"x.y",
+			map[string]interface{}{
+				"x": map[string]interface{}{
+					"y": 5,
+				},
+			},
+			5,
+		},
+		{
 			`x["y"]`,
 			map[string]interface{}{
 				"x": map[string]interface{}{
@@ -374,61 +1071,515 @@ func TestFieldExpression(t *testing.T) {
 
 	for i, test := range tests {
 		s := scope.Scope{}
-
-		for k, v := range test.scopeValues {
-			s.Set(k, v)
-		}
+
+		for k, v := range test.scopeValues {
+			s.Set(k, v)
+		}
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestFieldExpression_Index(t *testing.T) {
+	tests := []struct {
+		input    string
+		value    interface{}
+		expected interface{}
+	}{
+		{"x[0]", "hello", "h"},
+		{"x[4]", "hello", "o"},
+		{"x[1]", "a€b", "€"},
+		{"x[2]", "a€b", "b"},
+		{"x[1]", []int{1, 2, 3}, 2},
+		{"x[2]", [3]string{"a", "b", "c"}, "c"},
+		{`"hello"[1]`, nil, "e"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("x", test.value)
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestFieldExpression_IndexError(t *testing.T) {
+	tests := []struct {
+		input    string
+		value    interface{}
+		expected string
+	}{
+		{"x[5]", "hello", "line 1, column 1: index out of range in string of length 5: 5"},
+		{"x[3]", "a€b", "line 1, column 1: index out of range in string of length 3: 3"},
+		{"x[-1]", "hello", "line 1, column 1: index out of range in string of length 5: -1"},
+		{"x[3]", []int{1, 2, 3}, "line 1, column 1: index out of range in object of type []int with length 3: 3"},
+		{"x[0]", true, "line 1, column 1: cannot get element 0 from object of type bool"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("x", test.value)
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestFieldExpression_Unexported(t *testing.T) {
+	tests := []struct {
+		input string
+		value interface{}
+	}{
+		{"x.unexported", MockUnexported{Exported: 1, unexported: 2}},
+		{"x.unexported", &MockUnexported{Exported: 1, unexported: 2}},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("x", test.value)
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.Contains(err.Error(), "line 1, column 1: field in object of type") || !strings.Contains(err.Error(), "is not exported: unexported") {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestCallExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"x.Five()",
+			5,
+		},
+		{
+			"x.Double(21)",
+			42,
+		},
+		{
+			"x.Sum(6, 7)",
+			13,
+		},
+		{
+			`x.SumWithMap({
+				"x": 10,
+				"y": 20
+			})`,
+			30,
+		},
+		{
+			"foo(3, 4)",
+			12,
+		},
+		{
+			`dimensions({
+				"Width": 10,
+				"Height": 20
+			})`,
+			"10x20",
+		},
+		{
+			`dimensions({
+				"Title": "box",
+				"Width": 3
+			})`,
+			"box 3x0",
+		},
+		{
+			`let x = foo
+			x(3, 4)`,
+			12,
+		},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("x", &MockObject{})
+
+		s.Set("foo", func(a int, b int) int {
+			return a * b
+		})
+
+		s.Set("dimensions", func(opts MockOptions) string {
+			d := fmt.Sprintf("%dx%d", opts.Width, opts.Height)
+			if opts.Title != "" {
+				d = opts.Title + " " + d
+			}
+			return d
+		})
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestCallExpression_ArgumentCountError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foo(1, 2, 3)", "too many arguments for function call to foo: expected 2, got 3"},
+		{"x.Sum(1, 2, 3)", "too many arguments for function call to x.Sum: expected 2, got 3"},
+		{"foo(1)", "cannot resolve argument #2 for function call to foo(int, int): int"},
+		{"x.Sum()", "cannot resolve argument #1 for function call to x.Sum(int, int): int"},
+		{"sum()", "cannot resolve argument #1 for function call to sum(string, ...int): string"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("x", &MockObject{})
+
+		s.Set("foo", func(a int, b int) int {
+			return a * b
+		})
+
+		s.Set("sum", func(p string, n ...int) int {
+			return 0
+		})
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if !strings.HasSuffix(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error, expected=%s, got=%v", i, test.expected, err)
+		}
+	}
+}
+
+func TestCallExpression_CallDepth(t *testing.T) {
+	s := scope.Scope{}
+	s.Set("depth", func(d CallDepth) int {
+		return int(d)
+	})
+
+	prog := parse(0, "depth()", t, lexer.WithStartInCodeMode())
+
+	o, err := New(WithCallDepth(3)).Eval(prog, &s)
+	if err != nil {
+		t.Fatalf("error evaluating expression: %v", err)
+	}
+	testObject(0, o, 4, t)
+
+	_, err = New(WithCallDepth(3), WithMaxCallDepth(3)).Eval(prog, &s)
+	if err == nil || !strings.HasSuffix(err.Error(), "line 1, column 1: maximum call depth exceeded in function call to depth: 3") {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestCallExpression_CallDepth_Panic(t *testing.T) {
+	s := scope.Scope{}
+	s.Set("boom", func() int {
+		panic("boom")
+	})
+	s.Set("depth", func(d CallDepth) int {
+		return int(d)
+	})
+
+	ev := New()
+
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+
+		_, _ = ev.Eval(parse(0, "boom()", t, lexer.WithStartInCodeMode()), &s)
+	}()
+
+	o, err := ev.Eval(parse(0, "depth()", t, lexer.WithStartInCodeMode()), &s)
+	if err != nil {
+		t.Fatalf("error evaluating expression: %v", err)
+	}
+	testObject(0, o, 1, t) // call depth restored after panic
+}
+
+func TestCallExpression_Variadic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sum("x")`, 1},
+		{`sum("x", 1)`, 2},
+		{`sum("xy", 1, 2, 3)`, 8},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("sum", func(p string, n ...int) int {
+			sum := len(p)
+			for _, v := range n {
+				sum += v
+			}
+			return sum
+		})
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestCallExpression_Optional(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`greet("foo")`, "hello foo"},
+		{`greet("foo", "hi")`, "hi foo"},
+		{`greet("foo", nil)`, "<nil> foo"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("greet", func(name string, greeting Optional) string {
+			if !greeting.Valid {
+				return "hello " + name
+			}
+			return fmt.Sprintf("%v %s", greeting.Value, name)
+		})
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestCallExpression_MultipleReturnValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`intBool()`, []interface{}{5, true}},
+		{`intError(false)`, 5},
+		{`intBoolError(false)`, []interface{}{5, true}},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		setMultipleReturnValuesFuncs(&s)
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestCallExpression_MultipleReturnValuesError(t *testing.T) {
+	tests := []string{
+		`intError(true)`,
+		`intBoolError(true)`,
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		setMultipleReturnValuesFuncs(&s)
+
+		err := evalWithScopeError(i, test, &s, t, lexer.WithStartInCodeMode())
+		if !errors.Is(err, errMock) {
+			t.Fatalf("[%d] wrong error, expected=%v, got=%v", i, errMock, err)
+		}
+	}
+}
+
+func TestCallExpression_SingleReturnValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`noResult()`, nil},
+		{`onlyError(false)`, nil},
+		{`onlyValue()`, "foo"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		setSingleReturnValueFuncs(&s)
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestCallExpression_SingleReturnValueError(t *testing.T) {
+	s := scope.Scope{}
+	setSingleReturnValueFuncs(&s)
+
+	err := evalWithScopeError(0, `onlyError(true)`, &s, t, lexer.WithStartInCodeMode())
+	if !errors.Is(err, errMock) {
+		t.Fatalf("wrong error, expected=%v, got=%v", errMock, err)
+	}
+}
+
+func TestCallExpression_TypedNil(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`typedNil()`, nil},
+		{`typedNil() == nil`, true},
+		{`typedNil() != nil`, false},
+		{`nil == typedNil()`, true},
+		{`let x = typedNil()
+		if x == nil "none" else "some" end`, "none"},
+		{`object() == nil`, false},
+		{`object() != nil`, true},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("typedNil", func() *MockObject {
+			return nil
+		})
+
+		s.Set("object", func() *MockObject {
+			return &MockObject{}
+		})
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func setSingleReturnValueFuncs(s *scope.Scope) {
+	s.Set("noResult", func() {})
+
+	s.Set("onlyError", func(fail bool) error {
+		if fail {
+			return errMock
+		}
+		return nil
+	})
+
+	s.Set("onlyValue", func() string {
+		return "foo"
+	})
+}
+
+func setMultipleReturnValuesFuncs(s *scope.Scope) {
+	s.Set("intBool", func() (int, bool) {
+		return 5, true
+	})
+
+	s.Set("intError", func(fail bool) (int, error) {
+		if fail {
+			return 0, errMock
+		}
+		return 5, nil
+	})
+
+	s.Set("intBoolError", func(fail bool) (int, bool, error) {
+		if fail {
+			return 0, false, errMock
+		}
+		return 5, true, nil
+	})
+}
+
+func TestBuiltinLen(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("foo")`, 3},
+		{`len("h€llo")`, 5},
+		{`len(slice)`, 3},
+		{`len(array)`, 2},
+		{`len(hash)`, 1},
+		{`len({ "a": 1, "b": 2 })`, 2},
+		{`len(slice) > 0`, true},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+		s.Set("slice", []int{1, 2, 3})
+		s.Set("array", [2]string{})
+		s.Set("hash", map[string]bool{"x": true})
 
 		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
 		testObject(i, o, test.expected, t)
 	}
 }
 
-func TestCallExpression(t *testing.T) {
+func TestBuiltinLen_Error(t *testing.T) {
 	tests := []struct {
 		input    string
+		expected string
+	}{
+		{`len(5)`, "line 1, column 1: cannot get length of unsupported type: int64"},
+		{`len(nil)`, "line 1, column 1: cannot get length of nil"},
+		{`len()`, "line 1, column 1: wrong number of arguments for function call to len: expected 1, got 0"},
+		{`len("a", "b")`, "line 1, column 1: wrong number of arguments for function call to len: expected 1, got 2"},
+	}
+
+	for i, test := range tests {
+		err := evalWithScopeError(i, test.input, &scope.Scope{}, t, lexer.WithStartInCodeMode())
+		if !IsEvaluationError(err) || !strings.HasSuffix(err.Error(), test.expected) {
+			t.Fatalf("[%d] wrong error, expected=%s, got=%v", i, test.expected, err)
+		}
+	}
+}
+
+func TestBuiltinLen_Shadowed(t *testing.T) {
+	s := scope.Scope{}
+	s.Set("len", func(v interface{}) int {
+		return 42
+	})
+
+	o := evalWithScope(0, `len("foo")`, &s, t, lexer.WithStartInCodeMode())
+	testObject(0, o, 42, t)
+}
+
+func TestSwitchExpression(t *testing.T) {
+	tmpl := `switch x
+		case < 10
+			"small"
+		case < 100
+			"medium"
+		else
+			"large"
+		end`
+
+	tests := []struct {
+		x        int
 		expected interface{}
 	}{
-		{
-			"x.Five()",
-			5,
-		},
-		{
-			"x.Double(21)",
-			42,
-		},
-		{
-			"x.Sum(6, 7)",
-			13,
-		},
-		{
-			`x.SumWithMap({
-				"x": 10,
-				"y": 20
-			})`,
-			30,
-		},
-		{
-			"foo(3, 4)",
-			12,
-		},
-		{
-			`let x = foo
-			x(3, 4)`,
-			12,
-		},
+		{-5, "small"},
+		{9, "small"},
+		{10, "medium"},
+		{99, "medium"},
+		{100, "large"},
+		{1000, "large"},
 	}
 
 	for i, test := range tests {
 		s := scope.Scope{}
+		s.Set("x", test.x)
 
-		s.Set("x", &MockObject{})
+		o := evalWithScope(i, tmpl, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
 
-		s.Set("foo", func(a int, b int) int {
-			return a * b
-		})
+func TestSwitchExpression_Equality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`switch "b" case "a" 1 case "b" 2 case "c" 3 end`, 2},
+		{`switch "d" case "a" 1 case "b" 2 end`, nil},
+		{`switch 5 case != 5 1 case 5 2 end`, 2},
+		{`switch nil case nil 1 else 2 end`, 1},
+		{`switch 1 end`, nil},
+	}
 
-		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+	for i, test := range tests {
+		o := evalExpr(i, test.input, t, lexer.WithStartInCodeMode())
 		testObject(i, o, test.expected, t)
 	}
 }
@@ -503,6 +1654,205 @@ func TestForStatement(t *testing.T) {
 	}
 }
 
+func TestForStatement_Else(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`for i in range(1, 1)
+				"item"
+			else
+				"empty"
+			end`,
+			"empty",
+		},
+		{
+			`for i in range(1, 3)
+				i
+			else
+				"empty"
+			end`,
+			[]interface{}{1, 2},
+		},
+		{
+			// else does not run if the loop is left using break
+			`for i in range(1, 3)
+				break
+			else
+				"empty"
+			end`,
+			nil,
+		},
+		{
+			// break inside else applies to the outer loop
+			`for i in range(1, 4)
+				i
+				for j in range(1, 1)
+				else
+					break
+				end
+			end`,
+			[]interface{}{1, nil},
+		},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("range", ranger.NewInt)
+
+		o := evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testObject(i, o, test.expected, t)
+	}
+}
+
+func TestForStatement_Pair(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`let x = ""
+			for a, b in zip(xs, ys)
+				let x = x + a + b
+			end`,
+			"a1b2c3",
+		},
+		{
+			// without a second identifier, pairs are not destructured
+			`let x = ""
+			for p in zip(xs, ys)
+				let x = x + p.First
+			end`,
+			"abc",
+		},
+		{
+			// values that are not pairs still bind the loop status
+			`let x = 0
+			for a, st in range(xs)
+				let x = x + st.Index
+			end`,
+			3,
+		},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("zip", ranger.NewZip)
+		s.Set("range", ranger.New)
+		s.Set("xs", []string{"a", "b", "c"})
+		s.Set("ys", []string{"1", "2", "3", "4"})
+
+		evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		v, _ := s.Value("x")
+		testObject(i, v, test.expected, t)
+	}
+}
+
+func TestForStatement_KeyValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`let x = ""
+			for k: v in range(hash)
+				let x = x + k + "=" + v + ";"
+			end`,
+			"a=1;b=2;c=3;",
+		},
+		{
+			`let x = 0
+			for k: v, st in range(hash)
+				let x = x + st.Number
+			end`,
+			6,
+		},
+		{
+			`let x = ""
+			for a: b in zip(xs, ys)
+				let x = x + a + b
+			end`,
+			"a1b2",
+		},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("range", ranger.New)
+		s.Set("zip", ranger.NewZip)
+		s.Set("hash", map[string]interface{}{"c": "3", "a": "1", "b": "2"})
+		s.Set("xs", []string{"a", "b"})
+		s.Set("ys", []string{"1", "2"})
+
+		evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		v, _ := s.Value("x")
+		testObject(i, v, test.expected, t)
+	}
+}
+
+func TestForStatement_KeyValue_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for k: v in range(xs) end`, "evaluation error at line 1, column 5: cannot destructure object of type string into key and value"},
+		{`for k: xs in range(xs) end`, "evaluation error at line 1, column 8: value identifier in for statement already in use: xs"},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		s.Set("range", ranger.New)
+		s.Set("xs", []string{"a", "b"})
+
+		err := evalWithScopeError(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		if err.Error() != test.expected {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+	}
+}
+
+func TestForStatement_LoopTimeout(t *testing.T) {
+	prog := parse(0, `for i in range(0, 100) sleep() end`, t, lexer.WithStartInCodeMode())
+
+	s := scope.Scope{}
+	s.Set("range", ranger.NewInt)
+	s.Set("sleep", func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	ev := New(WithLoopTimeout(30 * time.Millisecond))
+
+	_, err := ev.Eval(prog, &s)
+	if err == nil || err.Error() != "evaluation error at line 1, column 1: time limit of for loop exceeded: 30ms" {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestBlockExpression(t *testing.T) {
+	prog := parse(0, `extends "other" let x = 1 block "a" x end + block "b" 10 end`, t, lexer.WithStartInCodeMode())
+
+	o, err := New().Eval(prog, &scope.Scope{})
+	if err != nil {
+		t.Fatalf("error evaluating expression: %v", err)
+	}
+	testObject(0, o, 11, t)
+
+	o, err = New(WithBlocks(map[string]ast.Block{
+		"b": {
+			Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewInfix(ast.NewIdent("x"), "*", ast.NewIntLiteral(100)))},
+		},
+	})).Eval(prog, &scope.Scope{})
+	if err != nil {
+		t.Fatalf("error evaluating expression: %v", err)
+	}
+	testObject(0, o, 101, t)
+}
+
 func TestCaptureExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -535,6 +1885,30 @@ func TestCaptureExpression(t *testing.T) {
 	}
 }
 
+func TestDoExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = do let a = 5 a + 1 end`, 6},
+		{`let x = do "a" "b" "c" end`, "c"},
+		{`let x = do end`, nil},
+		{`let x = do let a = 5 end`, nil},
+	}
+
+	for i, test := range tests {
+		s := scope.Scope{}
+
+		evalWithScope(i, test.input, &s, t, lexer.WithStartInCodeMode())
+		testScopeValue(i, &s, "x", test.expected, t)
+
+		// locals of the block are not visible outside of it
+		if s.HasValue("a") {
+			t.Fatalf("[%d] local identifier visible outside of block", i)
+		}
+	}
+}
+
 func TestStartInLiteral(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -566,6 +1940,8 @@ func testObject(i int, actual interface{}, expected interface{}, t *testing.T) {
 	t.Helper()
 
 	switch e := expected.(type) {
+	case nil:
+		testNilObject(i, actual, t)
 	case int:
 		testIntObject(i, actual, int64(e), t)
 	case int64:
@@ -582,7 +1958,7 @@ func testObject(i int, actual interface{}, expected interface{}, t *testing.T) {
 }
 
 func mustToInt64(v interface{}) int64 {
-	i, _ := toInt64(v)
+	i, _ := ToInt64(v)
 	return i
 }
 
@@ -601,6 +1977,14 @@ func mustToSlice(v interface{}) []interface{} {
 	return s
 }
 
+func testNilObject(i int, actual interface{}, t *testing.T) {
+	t.Helper()
+
+	if actual != nil {
+		t.Fatalf("[%d] wrong value, expected=nil, got=%v", i, actual)
+	}
+}
+
 func testIntObject(i int, actual interface{}, expected int64, t *testing.T) {
 	t.Helper()
 
@@ -651,6 +2035,127 @@ func testScopeValue(i int, s *scope.Scope, name string, expected interface{}, t
 	}
 }
 
+func TestFold(t *testing.T) {
+	tests := []string{
+		`1 + 2 * 3`,
+		`(1 + 2) * 3 - -4`,
+		`"a" + "b" + "c"`,
+		`1 < 2 && "a" != "b"`,
+		`!(1 == 2) || false`,
+		`nil == nil`,
+		`x + 1 * 2`,
+		`1 / 0 == 0 || true`,
+		`if 1 + 1 == 2 "yes" else "no" end`,
+		`for i in range(0, 2 + 1) i * (2 + 3) end`,
+		`{ a: 1 + 2 }.a`,
+		`let y = 3 * 4
+		y`,
+	}
+
+	for i, input := range tests {
+		prog := parse(i, input, t, lexer.WithStartInCodeMode())
+
+		ev := New()
+
+		folded := ev.Fold(prog)
+
+		s := scope.Scope{}
+		s.Set("x", 5)
+		s.Set("range", ranger.NewInt)
+
+		expected, expectedErr := ev.Eval(prog, &s)
+		o, err := ev.Eval(folded, &s)
+
+		if fmt.Sprint(expectedErr) != fmt.Sprint(err) {
+			t.Fatalf("[%d] wrong error, expected=%v, got=%v", i, expectedErr, err)
+		}
+
+		if !reflect.DeepEqual(o, expected) {
+			t.Fatalf("[%d] wrong result, expected=%#v, got=%#v", i, expected, o)
+		}
+	}
+}
+
+func TestFold_OperatorFunc(t *testing.T) {
+	calls := 0
+	plus := func(l interface{}, r interface{}) (interface{}, error) {
+		calls++
+		return nil, ErrOperatorNotHandled
+	}
+
+	ev := New(WithOperatorFunc("+", plus))
+
+	prog := parse(0, `1 + 2 + 3 * 4`, t, lexer.WithStartInCodeMode())
+	folded := ev.Fold(prog)
+
+	if calls != 0 {
+		t.Fatalf("operator function must not be called by Fold, calls=%d", calls)
+	}
+
+	e := folded.Statements[0].(*ast.ExpressionStatement).Expression
+	infix, ok := e.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("expression must not be folded, got=%#v", e)
+	}
+	if _, ok := infix.Right.(*ast.IntLiteral); !ok {
+		t.Fatalf("operand without operator function must be folded, got=%#v", infix.Right)
+	}
+
+	o, err := ev.Eval(folded, &scope.Scope{})
+	if err != nil {
+		t.Fatalf("error evaluating: %v", err)
+	}
+	testObject(0, o, 15, t)
+
+	if calls != 2 {
+		t.Fatalf("operator function must be called when evaluating, calls=%d", calls)
+	}
+}
+
+func TestFold_Literals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Expression
+	}{
+		{`1 + 2 * 3`, ast.NewIntLiteral(7)},
+		{`-(2 + 3)`, ast.NewIntLiteral(-5)},
+		{`"a" + "b"`, ast.NewStringLiteral("ab")},
+		{`!true`, ast.NewBoolLiteral(false)},
+		{`x + 1`, nil},
+		{`f(1 + 2)`, nil},
+		{`1 / 0`, nil},
+	}
+
+	for i, test := range tests {
+		prog := parse(i, test.input, t, lexer.WithStartInCodeMode())
+		folded := New().Fold(prog)
+
+		e := folded.Statements[0].(*ast.ExpressionStatement).Expression
+
+		v, ok := constantValue(e)
+
+		if test.expected == nil {
+			if ok {
+				t.Fatalf("[%d] expression must not be folded, got=%#v", i, e)
+			}
+			continue
+		}
+
+		expected, _ := constantValue(test.expected)
+
+		if !ok || reflect.TypeOf(e) != reflect.TypeOf(test.expected) || !reflect.DeepEqual(v, expected) {
+			t.Fatalf("[%d] wrong folded expression, expected=%#v, got=%#v", i, test.expected, e)
+		}
+	}
+
+	prog := parse(0, `1 + 2`, t, lexer.WithStartInCodeMode())
+	New().Fold(prog)
+
+	if _, ok := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression); !ok {
+		t.Fatalf("original program must not be modified")
+	}
+}
+
 func evalWithScope(i int, input string, s *scope.Scope, t *testing.T, lexerOpts ...lexer.Opt) interface{} {
 	t.Helper()
 
@@ -666,6 +2171,21 @@ func evalWithScope(i int, input string, s *scope.Scope, t *testing.T, lexerOpts
 	return o
 }
 
+func evalWithScopeError(i int, input string, s *scope.Scope, t *testing.T, lexerOpts ...lexer.Opt) error {
+	t.Helper()
+
+	prog := parse(i, input, t, lexerOpts...)
+
+	ev := New()
+
+	_, err := ev.Eval(prog, s)
+	if err == nil {
+		t.Fatalf("[%d] expected error evaluating expression", i)
+	}
+
+	return err
+}
+
 func evalExpr(i int, input string, t *testing.T, lexerOpts ...lexer.Opt) interface{} {
 	t.Helper()
 
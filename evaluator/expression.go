@@ -2,12 +2,20 @@ package evaluator
 
 import (
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/ranger"
 	"github.com/blizzy78/copper/scope"
 )
 
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	callDepthType = reflect.TypeOf(CallDepth(0))
+	optionalType  = reflect.TypeOf(Optional{})
+)
+
 func (ev *Evaluator) evalExpression(e ast.Expression) (interface{}, error) { //nolint:gocyclo
 	switch ex := e.(type) {
 	case *ast.NilLiteral:
@@ -28,12 +36,18 @@ func (ev *Evaluator) evalExpression(e ast.Expression) (interface{}, error) { //n
 		return ev.evalInfixExpression(*ex)
 	case *ast.IfExpression:
 		return ev.evalIfExpression(*ex)
+	case *ast.SwitchExpression:
+		return ev.evalSwitchExpression(*ex)
 	case *ast.FieldExpression:
 		return ev.evalFieldExpression(*ex)
 	case *ast.CallExpression:
 		return ev.evalCallExpression(*ex)
 	case *ast.CaptureExpression:
 		return ev.evalCaptureExpression(*ex)
+	case *ast.DoExpression:
+		return ev.evalBlock(ex.Block)
+	case *ast.BlockExpression:
+		return ev.evalBlockExpression(*ex)
 	case *ast.ForExpression:
 		return ev.evalForExpression(*ex)
 	case *ast.HashExpression:
@@ -50,7 +64,7 @@ func (ev *Evaluator) evalLiteral(l ast.Literal) (interface{}, error) {
 func (ev *Evaluator) evalIdentExpression(i ast.Ident) (interface{}, error) {
 	name := i.Name
 	o, ok := ev.scope.Value(name)
-	if !ok {
+	if !ok && ev.strictVars {
 		return nil, newEvalErrorf(i.StartLine, i.StartCol, "identifier not found in scope: %s", name)
 	}
 	return o, nil
@@ -85,12 +99,60 @@ func (ev *Evaluator) evalIfExpression(i ast.IfExpression) (interface{}, error) {
 	return nil, nil
 }
 
+func (ev *Evaluator) evalSwitchExpression(s ast.SwitchExpression) (interface{}, error) {
+	subject, err := ev.eval(s.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.Cases {
+		match := true
+
+		if c.Value != nil {
+			v, err := ev.eval(c.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			m, err := ev.evalInfixValues(subject, v, c.Operator, c.StartLine, c.StartCol)
+			if err != nil {
+				return nil, err
+			}
+
+			match, err = toBool(m)
+			if err != nil {
+				return nil, newEvalErrorf(c.StartLine, c.StartCol, "comparison in case block is not bool: %T", m)
+			}
+		}
+
+		if match {
+			os, err := ev.evalBlockCaptureAll(c.Block)
+			if err != nil {
+				return nil, err
+			}
+
+			return toSingleOrSliceObject(os), nil
+		}
+	}
+
+	return nil, nil
+}
+
 func (ev *Evaluator) evalForExpression(f ast.ForExpression) (interface{}, error) {
 	name := f.Ident.Name
 	if ev.scope.HasValue(name) {
 		return nil, newEvalErrorf(f.Ident.StartLine, f.Ident.StartCol, "identifier in for statement already in use: %s", name)
 	}
 
+	var valueName *string
+	if f.ValueIdent != nil {
+		valueName = &f.ValueIdent.Name
+	}
+
+	if valueName != nil && ev.scope.HasValue(*valueName) {
+		return nil, newEvalErrorf(f.ValueIdent.StartLine, f.ValueIdent.StartCol, "value identifier in for statement already in use: %s", *valueName)
+	}
+
 	var statusName *string
 	if f.StatusIdent != nil {
 		statusName = &f.StatusIdent.Name
@@ -110,6 +172,26 @@ func (ev *Evaluator) evalForExpression(f ast.ForExpression) (interface{}, error)
 		return nil, newEvalErrorf(f.RangeExpr.Line(), f.RangeExpr.Col(), "range expression in for statement did not produce a ranger.Ranger: %T", r)
 	}
 
+	os, n, err := ev.evalForLoop(f, rg, name, valueName, statusName)
+	if err != nil {
+		return nil, err
+	}
+
+	// the else block is evaluated outside of the loop, so that break and continue apply to outer loops
+	if n == 0 && f.ElseBlock != nil {
+		os, err = ev.evalBlockCaptureAll(*f.ElseBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return toSingleOrSliceObject(os), nil
+}
+
+// evalForLoop executes the block of the for expression f for each value of rg, and returns the captured output,
+// as well as the number of iterations.
+func (ev *Evaluator) evalForLoop(f ast.ForExpression, rg ranger.Ranger, name string, valueName *string,
+	statusName *string) ([]interface{}, int, error) {
 	defer func(oldScope *scope.Scope) {
 		ev.scope = oldScope
 		ev.loopLevel--
@@ -123,19 +205,46 @@ func (ev *Evaluator) evalForExpression(f ast.ForExpression) (interface{}, error)
 	ev.loopLevel++
 
 	os := []interface{}{}
+	n := 0
+
+	start := time.Now()
 
 	for rg.Next() {
+		n++
+
+		if ev.loopTimeout > 0 && time.Since(start) > ev.loopTimeout {
+			return nil, 0, newEvalErrorf(f.StartLine, f.StartCol, "time limit of for loop exceeded: %s", ev.loopTimeout)
+		}
+
 		v := rg.Value()
 
 		loopScope.ClearSelf()
-		loopScope.Set(name, v)
-		if statusName != nil {
-			loopScope.Set(*statusName, rg.Status())
+
+		if valueName != nil {
+			k, kv, ok := keyValue(v)
+			if !ok {
+				return nil, 0, newEvalErrorf(f.Ident.StartLine, f.Ident.StartCol, "cannot destructure object of type %T into key and value", v)
+			}
+
+			loopScope.Set(name, k)
+			loopScope.Set(*valueName, kv)
+			if statusName != nil {
+				loopScope.Set(*statusName, rg.Status())
+			}
+		} else if p, ok := v.(ranger.Pair); ok && statusName != nil {
+			// a pair is destructured into both identifiers, taking precedence over the status
+			loopScope.Set(name, p.First)
+			loopScope.Set(*statusName, p.Second)
+		} else {
+			loopScope.Set(name, v)
+			if statusName != nil {
+				loopScope.Set(*statusName, rg.Status())
+			}
 		}
 
 		loopOs, err := ev.evalBlockCaptureAll(f.Block)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		os = append(os, loopOs...)
@@ -148,10 +257,27 @@ func (ev *Evaluator) evalForExpression(f ast.ForExpression) (interface{}, error)
 		ev.continueRequested = false
 	}
 
-	return toSingleOrSliceObject(os), nil
+	return os, n, nil
+}
+
+// keyValue returns the key and value of v if it is a ranger.HashEntry, or the first and second value of v
+// if it is a ranger.Pair.
+func keyValue(v interface{}) (interface{}, interface{}, bool) {
+	switch value := v.(type) {
+	case ranger.HashEntry:
+		return value.Key, value.Value, true
+	case ranger.Pair:
+		return value.First, value.Second, true
+	default:
+		return nil, nil, false
+	}
 }
 
 func (ev *Evaluator) evalCallExpression(c ast.CallExpression) (interface{}, error) {
+	if b, ok := ev.builtin(c); ok {
+		return ev.evalBuiltinCall(b, c)
+	}
+
 	f, err := ev.eval(c.Callee)
 	if err != nil {
 		return nil, err
@@ -165,8 +291,15 @@ func (ev *Evaluator) evalCallExpression(c ast.CallExpression) (interface{}, erro
 	fValueType := fValue.Type()
 	numExpectedParams := fValueType.NumIn()
 
-	if len(c.Params) > numExpectedParams {
-		return nil, newEvalErrorf(c.StartLine, c.StartCol, "too many arguments for function call")
+	// the variadic parameter is optional, so it is not counted as required
+	numRequiredParams := numExpectedParams
+	if fValueType.IsVariadic() {
+		numRequiredParams--
+	}
+
+	if !fValueType.IsVariadic() && len(c.Params) > numExpectedParams {
+		return nil, newEvalErrorf(c.StartLine, c.StartCol, "too many arguments for function call to %s: expected %d, got %d",
+			exprString(c.Callee), numExpectedParams, len(c.Params))
 	}
 
 	params := []reflect.Value{}
@@ -177,50 +310,50 @@ func (ev *Evaluator) evalCallExpression(c ast.CallExpression) (interface{}, erro
 			return nil, err
 		}
 
-		pType := fValueType.In(i)
-		if po != nil {
-			pValue := reflect.ValueOf(po)
-			if !pValue.Type().ConvertibleTo(pType) {
-				return nil, newEvalErrorf(e.Line(), e.Col(), "cannot convert argument of type %T to required type %s", po, pType)
-			}
-
-			pValue = pValue.Convert(pType)
-			params = append(params, pValue)
-		} else {
-			nilValue := reflect.New(pType).Elem()
-			params = append(params, nilValue)
+		pType := paramType(fValueType, i)
+		pValue, err := toArgument(po, pType)
+		if err != nil {
+			return nil, newEvalError(err, e.Line(), e.Col())
 		}
+
+		params = append(params, pValue)
 	}
 
-	for i := len(c.Params); i < numExpectedParams; i++ {
+	for i := len(c.Params); i < numRequiredParams; i++ {
 		pType := fValueType.In(i)
-		ok := false
-		for _, ra := range ev.argumentResolvers {
-			v, err := ra.Resolve(pType)
-			if err != nil {
-				return nil, err
-			}
-			if v == nil {
-				continue
-			}
+		if pType == optionalType {
+			params = append(params, reflect.ValueOf(Optional{}))
+			continue
+		}
 
-			vValue := reflect.ValueOf(v)
-			pValue := vValue.Convert(pType)
-			params = append(params, pValue)
-			ok = true
-			break
+		pValue, ok, err := ev.resolveArgument(pType)
+		if err != nil {
+			return nil, err
 		}
 
-		if !ok {
-			return nil, newEvalErrorf(c.StartLine, c.StartCol, "cannot resolve argument #%d for function call: %s", i+1, pType.Name())
+		if ok {
+			params = append(params, pValue)
+		} else {
+			return nil, newEvalErrorf(c.StartLine, c.StartCol, "cannot resolve argument #%d for function call to %s(%s): %s",
+				i+1, exprString(c.Callee), paramTypeNames(fValueType), pType)
 		}
 	}
 
-	if len(params) != numExpectedParams {
-		return nil, newEvalErrorf(c.StartLine, c.StartCol, "not enough arguments for function call")
+	if len(params) < numRequiredParams {
+		return nil, newEvalErrorf(c.StartLine, c.StartCol, "not enough arguments for function call to %s: expected %d, got %d",
+			exprString(c.Callee), numRequiredParams, len(params))
+	}
+
+	if ev.maxCallDepth > 0 && ev.callDepth >= ev.maxCallDepth {
+		return nil, newEvalErrorf(c.StartLine, c.StartCol, "maximum call depth exceeded in function call to %s: %d",
+			exprString(c.Callee), ev.maxCallDepth)
 	}
 
+	ev.callDepth++
+	defer func() { ev.callDepth-- }()
+
 	rs := fValue.Call(params)
+
 	if len(rs) == 0 {
 		return nil, nil
 	}
@@ -232,7 +365,7 @@ func (ev *Evaluator) evalCallExpression(c ast.CallExpression) (interface{}, erro
 		}
 	}
 
-	return rs[0].Interface(), nil
+	return callResult(fValueType, rs), nil
 }
 
 func (ev *Evaluator) evalCaptureExpression(c ast.CaptureExpression) (interface{}, error) {
@@ -243,6 +376,19 @@ func (ev *Evaluator) evalCaptureExpression(c ast.CaptureExpression) (interface{}
 	return toSingleOrSliceObject(os), nil
 }
 
+func (ev *Evaluator) evalBlockExpression(b ast.BlockExpression) (interface{}, error) {
+	block := b.Block
+	if o, ok := ev.blocks[b.Name]; ok {
+		block = o
+	}
+
+	os, err := ev.evalBlockCaptureAll(block)
+	if err != nil {
+		return nil, err
+	}
+	return toSingleOrSliceObject(os), nil
+}
+
 func (ev *Evaluator) evalHashExpression(h ast.HashExpression) (interface{}, error) {
 	values := map[string]interface{}{}
 
@@ -262,6 +408,91 @@ func (ev *Evaluator) evalHashExpression(h ast.HashExpression) (interface{}, erro
 	return values, nil
 }
 
+// resolveArgument resolves an additional argument of type t for a method or function call, using the
+// evaluator's argument resolvers. ok will be false if no resolver produced a value. An argument of type
+// CallDepth is always resolved to the call depth of the call.
+func (ev *Evaluator) resolveArgument(t reflect.Type) (reflect.Value, bool, error) {
+	if t == callDepthType {
+		return reflect.ValueOf(CallDepth(ev.callDepth + 1)), true, nil
+	}
+
+	for _, ra := range ev.argumentResolvers {
+		v, err := ra.Resolve(t)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+		if v == nil {
+			continue
+		}
+
+		return reflect.ValueOf(v).Convert(t), true, nil
+	}
+
+	return reflect.Value{}, false, nil
+}
+
+// callResult returns the values rs returned by a call to a function of type f. If the last return value
+// of f is of type error, it is not included. If more than one value remains, the values are returned
+// as a slice, otherwise the single value (or nil) is returned.
+func callResult(f reflect.Type, rs []reflect.Value) interface{} {
+	if f.Out(len(rs)-1) == errorType {
+		rs = rs[:len(rs)-1]
+	}
+
+	switch len(rs) {
+	case 0:
+		return nil
+	case 1:
+		return rs[0].Interface()
+	default:
+		os := make([]interface{}, len(rs))
+		for i, r := range rs {
+			os[i] = r.Interface()
+		}
+		return os
+	}
+}
+
+// paramType returns the type of the i-th parameter of the function type f. If f is variadic and i
+// refers to the variadic parameter or beyond, the element type of the variadic parameter is returned.
+func paramType(f reflect.Type, i int) reflect.Type {
+	if f.IsVariadic() && i >= f.NumIn()-1 {
+		return f.In(f.NumIn() - 1).Elem()
+	}
+	return f.In(i)
+}
+
+// paramTypeNames returns the names of the parameter types of the function type f, separated by commas.
+func paramTypeNames(f reflect.Type) string {
+	names := make([]string, f.NumIn())
+	for i := range names {
+		if f.IsVariadic() && i == len(names)-1 {
+			names[i] = "..." + f.In(i).Elem().String()
+			continue
+		}
+		names[i] = f.In(i).String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// exprString returns a textual representation of e, suitable for use in error messages.
+// It is not necessarily equal to the template source code that produced e.
+func exprString(e ast.Expression) string {
+	switch ex := e.(type) {
+	case *ast.Ident:
+		return ex.Name
+	case *ast.FieldExpression:
+		if index, ok := ex.Index.(*ast.StringLiteral); ok {
+			return exprString(ex.Callee) + "." + index.Value
+		}
+		return exprString(ex.Callee) + "[...]"
+	case *ast.CallExpression:
+		return exprString(ex.Callee) + "(...)"
+	default:
+		return "(...)"
+	}
+}
+
 func defaultLiteral(s string) (interface{}, error) {
 	return s, nil
 }
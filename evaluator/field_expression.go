@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"reflect"
+	"sort"
 
 	"github.com/blizzy78/copper/ast"
 )
@@ -12,6 +13,15 @@ func (ev *Evaluator) evalFieldExpression(f ast.FieldExpression) (interface{}, er
 		return nil, err
 	}
 
+	if i, err := ToInt64(index); err == nil {
+		callee, err := ev.eval(f.Callee)
+		if err != nil {
+			return nil, err
+		}
+
+		return evalIndexExpression(callee, i, f.StartLine, f.StartCol)
+	}
+
 	name, err := toString(index)
 	if err != nil {
 		return nil, newEvalErrorf(f.Index.Line(), f.Index.Col(), "type of index expression in field expression is not string: %T", index)
@@ -34,13 +44,82 @@ func (ev *Evaluator) evalFieldExpression(f ast.FieldExpression) (interface{}, er
 			return nil, err
 		}
 
-		return evalFieldExpressionHash(hash, name, f.StartLine, f.StartCol)
+		return ev.evalFieldExpressionHash(hash, name, f.StartLine, f.StartCol)
 
 	default:
 		return evalFieldExpressionNative(callee, name, f.StartLine, f.StartCol)
 	}
 }
 
+// MembersOf returns the names of the members of v that can be accessed using field expressions, sorted by name.
+// If v is a struct or a pointer to a struct, the names of its exported fields are returned. If v is a map,
+// the keys of its entries are returned instead. In addition, the names of v's exported methods are returned.
+// This is useful for tooling, for example to provide completions for field expressions in an editor.
+func MembersOf(v interface{}) []string {
+	names := []string{}
+
+	value := reflect.ValueOf(v)
+	if v == nil || (value.Kind() == reflect.Ptr && value.IsNil()) {
+		return names
+	}
+
+	for i := 0; i < value.NumMethod(); i++ {
+		names = append(names, value.Type().Method(i).Name)
+	}
+
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		t := value.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				names = append(names, f.Name)
+			}
+		}
+
+	case reflect.Map:
+		if m, err := toMap(v); err == nil {
+			for k := range m {
+				names = append(names, k)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// evalIndexExpression returns the element at index i of the slice or array callee. If callee is a string,
+// the character (rune) at index i is returned as a string.
+func evalIndexExpression(callee interface{}, i int64, line int, col int) (interface{}, error) {
+	if callee == nil {
+		return nil, newEvalErrorf(line, col, "cannot get element %d from nil object", i)
+	}
+
+	calleeValue := reflect.ValueOf(callee)
+
+	switch calleeValue.Kind() {
+	case reflect.String:
+		runes := []rune(calleeValue.String())
+		if i < 0 || i >= int64(len(runes)) {
+			return nil, newEvalErrorf(line, col, "index out of range in string of length %d: %d", len(runes), i)
+		}
+		return string(runes[i]), nil
+
+	case reflect.Slice, reflect.Array:
+		if i < 0 || i >= int64(calleeValue.Len()) {
+			return nil, newEvalErrorf(line, col, "index out of range in object of type %T with length %d: %d", callee, calleeValue.Len(), i)
+		}
+		return calleeValue.Index(int(i)).Interface(), nil
+
+	default:
+		return nil, newEvalErrorf(line, col, "cannot get element %d from object of type %T", i, callee)
+	}
+}
+
 func evalFieldExpressionNative(i interface{}, name string, line int, col int) (interface{}, error) {
 	iValue := reflect.ValueOf(i)
 	switch iValue.Kind() {
@@ -52,6 +131,10 @@ func evalFieldExpressionNative(i interface{}, name string, line int, col int) (i
 }
 
 func evalFieldExpressionNativeDirect(s interface{}, sValue reflect.Value, name string, line int, col int) (interface{}, error) {
+	if isUnexportedField(sValue, name) {
+		return nil, newEvalErrorf(line, col, "field in object of type %T is not exported: %s", s, name)
+	}
+
 	o := tryEvalFieldExpressionNativeDirectField(sValue, name)
 	if o == nil {
 		o = tryEvalFieldExpressionNativeDirectFunc(sValue, name)
@@ -63,6 +146,10 @@ func evalFieldExpressionNativeDirect(s interface{}, sValue reflect.Value, name s
 }
 
 func evalFieldExpressionNativePtr(s interface{}, sValue reflect.Value, name string, line int, col int) (interface{}, error) {
+	if isUnexportedField(sValue.Elem(), name) {
+		return nil, newEvalErrorf(line, col, "field in object of type %T is not exported: %s", s, name)
+	}
+
 	o := tryEvalFieldExpressionNativePtrField(sValue, name)
 	if o == nil {
 		o = tryEvalFieldExpressionNativePtrFunc(sValue, name)
@@ -73,6 +160,15 @@ func evalFieldExpressionNativePtr(s interface{}, sValue reflect.Value, name stri
 	return o, nil
 }
 
+// isUnexportedField returns whether sValue is a struct that has an unexported field identified by name.
+func isUnexportedField(sValue reflect.Value, name string) bool {
+	if sValue.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := sValue.Type().FieldByName(name)
+	return ok && f.PkgPath != ""
+}
+
 func tryEvalFieldExpressionNativeDirectField(sValue reflect.Value, name string) interface{} {
 	if sValue.Kind() != reflect.Struct {
 		return nil
@@ -108,9 +204,9 @@ func tryEvalFieldExpressionNativePtrFunc(sValue reflect.Value, name string) inte
 	return sValue.MethodByName(name).Interface()
 }
 
-func evalFieldExpressionHash(hash map[string]interface{}, name string, line int, col int) (interface{}, error) {
+func (ev *Evaluator) evalFieldExpressionHash(hash map[string]interface{}, name string, line int, col int) (interface{}, error) {
 	o, ok := hash[name]
-	if !ok {
+	if !ok && ev.strictVars {
 		return nil, newEvalErrorf(line, col, "key not found in map: %s", name)
 	}
 	return o, nil
@@ -0,0 +1,215 @@
+package evaluator
+
+import (
+	"github.com/blizzy78/copper/ast"
+)
+
+// Fold returns a copy of p in which infix and prefix expressions whose operands are all literal values,
+// such as "1 + 2 * 3" or `"a" + "b"`, are replaced by literals of their results. Only the builtin operators
+// on int, bool, and string operands are folded: expressions using an operator for which ev has operator
+// functions configured are left alone, so that those functions are still called when evaluating the program.
+// Expressions that involve identifiers or calls are never folded, and neither are expressions that would fail
+// to evaluate, so that the error is still reported when evaluating the program. p itself is not modified.
+func (ev *Evaluator) Fold(p *ast.Program) *ast.Program {
+	f := *p
+	f.Statements = ev.foldStatements(p.Statements)
+	return &f
+}
+
+func (ev *Evaluator) foldStatements(st []ast.Statement) []ast.Statement {
+	if st == nil {
+		return nil
+	}
+
+	f := make([]ast.Statement, len(st))
+	for i, s := range st {
+		f[i] = ev.foldStatement(s)
+	}
+
+	return f
+}
+
+func (ev *Evaluator) foldStatement(st ast.Statement) ast.Statement {
+	switch stmt := st.(type) {
+	case *ast.ExpressionStatement:
+		f := *stmt
+		f.Expression = ev.foldExpression(stmt.Expression)
+		return &f
+	case *ast.LetStatement:
+		f := *stmt
+		f.Expression = ev.foldExpression(stmt.Expression)
+		return &f
+	case *ast.AssignStatement:
+		f := *stmt
+		f.Target = ev.foldFieldExpression(stmt.Target)
+		f.Expression = ev.foldExpression(stmt.Expression)
+		return &f
+	default:
+		return st
+	}
+}
+
+func (ev *Evaluator) foldExpression(e ast.Expression) ast.Expression { //nolint:gocyclo
+	switch ex := e.(type) {
+	case *ast.PrefixExpression:
+		return ev.foldPrefixExpression(ex)
+	case *ast.InfixExpression:
+		return ev.foldInfixExpression(ex)
+	case *ast.IfExpression:
+		f := *ex
+		f.Conditionals = make([]ast.ConditionalBlock, len(ex.Conditionals))
+		for i, c := range ex.Conditionals {
+			c.Condition = ev.foldExpression(c.Condition)
+			c.Block = ev.foldBlock(c.Block)
+			f.Conditionals[i] = c
+		}
+		return &f
+	case *ast.SwitchExpression:
+		f := *ex
+		f.Subject = ev.foldExpression(ex.Subject)
+		f.Cases = make([]ast.CaseBlock, len(ex.Cases))
+		for i, c := range ex.Cases {
+			c.Value = ev.foldExpression(c.Value)
+			c.Block = ev.foldBlock(c.Block)
+			f.Cases[i] = c
+		}
+		return &f
+	case *ast.FieldExpression:
+		return ev.foldFieldExpression(ex)
+	case *ast.CallExpression:
+		f := *ex
+		f.Callee = ev.foldExpression(ex.Callee)
+		f.Params = make([]ast.Expression, len(ex.Params))
+		for i, p := range ex.Params {
+			f.Params[i] = ev.foldExpression(p)
+		}
+		return &f
+	case *ast.CaptureExpression:
+		f := *ex
+		f.Block = ev.foldBlock(ex.Block)
+		return &f
+	case *ast.DoExpression:
+		f := *ex
+		f.Block = ev.foldBlock(ex.Block)
+		return &f
+	case *ast.BlockExpression:
+		f := *ex
+		f.Block = ev.foldBlock(ex.Block)
+		return &f
+	case *ast.ForExpression:
+		f := *ex
+		f.RangeExpr = ev.foldExpression(ex.RangeExpr)
+		f.Block = ev.foldBlock(ex.Block)
+		if ex.ElseBlock != nil {
+			b := ev.foldBlock(*ex.ElseBlock)
+			f.ElseBlock = &b
+		}
+		return &f
+	case *ast.HashExpression:
+		f := *ex
+		f.Values = make(map[string]ast.Expression, len(ex.Values))
+		for k, v := range ex.Values {
+			f.Values[k] = ev.foldExpression(v)
+		}
+		return &f
+	default:
+		return e
+	}
+}
+
+func (ev *Evaluator) foldBlock(b ast.Block) ast.Block {
+	b.Statements = ev.foldStatements(b.Statements)
+	return b
+}
+
+func (ev *Evaluator) foldFieldExpression(fe *ast.FieldExpression) *ast.FieldExpression {
+	if fe == nil {
+		return nil
+	}
+
+	f := *fe
+	f.Callee = ev.foldExpression(fe.Callee)
+	f.Index = ev.foldExpression(fe.Index)
+	return &f
+}
+
+func (ev *Evaluator) foldPrefixExpression(p *ast.PrefixExpression) ast.Expression {
+	f := *p
+	f.Expression = ev.foldExpression(p.Expression)
+
+	if _, ok := constantValue(f.Expression); !ok {
+		return &f
+	}
+
+	o, err := ev.evalPrefixExpression(f)
+	if err != nil {
+		return &f
+	}
+
+	if l, ok := constantLiteral(normalize(o), f.StartLine, f.StartCol, f.Span); ok {
+		return l
+	}
+
+	return &f
+}
+
+func (ev *Evaluator) foldInfixExpression(i *ast.InfixExpression) ast.Expression {
+	f := *i
+	f.Left = ev.foldExpression(i.Left)
+	f.Right = ev.foldExpression(i.Right)
+
+	if len(ev.operatorFuncs[f.Operator]) > 0 {
+		return &f
+	}
+
+	left, ok := constantValue(f.Left)
+	if !ok {
+		return &f
+	}
+
+	right, ok := constantValue(f.Right)
+	if !ok {
+		return &f
+	}
+
+	o, err := ev.evalInfixValues(left, right, f.Operator, f.StartLine, f.StartCol)
+	if err != nil {
+		return &f
+	}
+
+	if l, ok := constantLiteral(normalize(o), f.StartLine, f.StartCol, f.Span); ok {
+		return l
+	}
+
+	return &f
+}
+
+// constantValue returns the value of e if e is an int, bool, or string literal, that is, an operand
+// of the builtin operators that can be folded.
+func constantValue(e ast.Expression) (interface{}, bool) {
+	switch ex := e.(type) {
+	case *ast.IntLiteral:
+		return ex.Value, true
+	case *ast.StringLiteral:
+		return ex.Value, true
+	case *ast.BoolLiteral:
+		return ex.Value, true
+	default:
+		return nil, false
+	}
+}
+
+// constantLiteral returns a literal expression for the value v at the position line and col,
+// if v can be represented as a literal.
+func constantLiteral(v interface{}, line int, col int, span ast.Span) (ast.Expression, bool) {
+	switch val := v.(type) {
+	case int64:
+		return &ast.IntLiteral{StartLine: line, StartCol: col, Value: val, Span: span}, true
+	case string:
+		return &ast.StringLiteral{StartLine: line, StartCol: col, Value: val, Span: span}, true
+	case bool:
+		return &ast.BoolLiteral{StartLine: line, StartCol: col, Value: val, Span: span}, true
+	default:
+		return nil, false
+	}
+}
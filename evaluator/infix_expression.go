@@ -1,7 +1,10 @@
 package evaluator
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/blizzy78/copper/ast"
 )
@@ -11,37 +14,69 @@ func (ev *Evaluator) evalInfixExpression(i ast.InfixExpression) (interface{}, er
 	if err != nil {
 		return nil, err
 	}
-	leftKind := reflect.ValueOf(left).Kind()
-
-	// short-circuit expressions like "falsy && ..."
-	if left != nil && leftKind == reflect.Bool && i.Operator == "&&" {
-		l, err := toBool(left)
-		if err != nil {
-			return nil, err
-		}
-		if !l {
-			return false, nil
-		}
-	}
 
-	// short-circuit expressions like "truthy || ..."
-	if left != nil && leftKind == reflect.Bool && i.Operator == "||" {
-		l, err := toBool(left)
-		if err != nil {
-			return nil, err
-		}
-		if l {
-			return true, nil
-		}
+	if o, ok, err := ev.shortCircuit(left, i.Operator, i.StartLine, i.StartCol); err != nil {
+		return nil, err
+	} else if ok {
+		return o, nil
 	}
 
 	right, err := ev.eval(i.Right)
 	if err != nil {
 		return nil, err
 	}
+
+	return ev.evalInfixValues(left, right, i.Operator, i.StartLine, i.StartCol)
+}
+
+// shortCircuit returns the result of an infix expression with operator op that can be determined from
+// its left operand alone, such as "falsy && ..." or "truthy || ...", and true. If the result depends on
+// the right operand, it returns false. If the left operand of "&&" or "||" is not a bool and no operator
+// function may handle it, it returns an error, so that the right operand is never evaluated needlessly.
+func (ev *Evaluator) shortCircuit(left interface{}, op string, line int, col int) (interface{}, bool, error) {
+	if op != "&&" && op != "||" {
+		return nil, false, nil
+	}
+
+	l, err := toBool(left)
+	if err != nil {
+		if len(ev.operatorFuncs[op]) > 0 {
+			return nil, false, nil
+		}
+		return nil, false, newEvalErrorf(line, col, "left operand of '%s' infix expression is not bool: %T", op, left)
+	}
+
+	switch {
+	case op == "&&" && !l:
+		return false, true, nil
+	case op == "||" && l:
+		return true, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// evalInfixValues applies the infix operator op to the values left and right.
+func (ev *Evaluator) evalInfixValues(left interface{}, right interface{}, op string, line int, col int) (interface{}, error) {
+	leftKind := reflect.ValueOf(left).Kind()
 	rightKind := reflect.ValueOf(right).Kind()
 
+	if o, ok, err := ev.evalInfixExpressionOperatorFuncs(left, right, op); err != nil {
+		return nil, newEvalError(err, line, col)
+	} else if ok {
+		return o, nil
+	}
+
+	if op == "+" {
+		if l, r, ok := stringerOperands(left, right, ev.stringCoercion); ok {
+			return evalStringInfixExpression(l, r, op, line, col)
+		}
+	}
+
 	switch {
+	case left == nil || right == nil:
+		return evalNilInfixExpression(left, right, op, line, col)
+
 	case left != nil && right != nil && leftKind == reflect.String && rightKind == reflect.String:
 		l, err := toString(left)
 		if err != nil {
@@ -53,20 +88,24 @@ func (ev *Evaluator) evalInfixExpression(i ast.InfixExpression) (interface{}, er
 			return nil, err
 		}
 
-		return evalStringInfixExpression(l, r, i.Operator, i.StartLine, i.StartCol)
+		return evalStringInfixExpression(l, r, op, line, col)
 
-	case left != nil && right != nil && leftKind == reflect.Int64 && rightKind == reflect.Int64:
-		l, err := toInt64(left)
+	case left != nil && right != nil && isIntKind(leftKind) && isIntKind(rightKind):
+		l, err := ToInt64(left)
 		if err != nil {
 			return nil, err
 		}
 
-		r, err := toInt64(right)
+		r, err := ToInt64(right)
 		if err != nil {
 			return nil, err
 		}
 
-		return evalIntInfixExpression(l, r, i.Operator, i.StartLine, i.StartCol)
+		if op == "%" && ev.euclideanMod {
+			return evalEuclideanMod(l, r, line, col)
+		}
+
+		return evalIntInfixExpression(l, r, op, line, col)
 
 	case left != nil && right != nil && leftKind == reflect.Bool && rightKind == reflect.Bool:
 		l, err := toBool(left)
@@ -79,10 +118,90 @@ func (ev *Evaluator) evalInfixExpression(i ast.InfixExpression) (interface{}, er
 			return nil, err
 		}
 
-		return evalBoolInfixExpression(l, r, i.Operator, i.StartLine, i.StartCol)
+		return evalBoolInfixExpression(l, r, op, line, col)
+
+	default:
+		return nil, newEvalErrorf(line, col, "cannot handle expression types in '%s' infix expression: %T vs %T", op, left, right)
+	}
+}
+
+// stringerOperands converts left and right to strings if one of them is a string and the other one is not,
+// but implements fmt.Stringer. If coerce is true, the other one may also be an integer or a bool.
+// It returns false if that is not the case.
+func stringerOperands(left interface{}, right interface{}, coerce bool) (string, string, bool) {
+	l, lok := stringOrStringer(left, coerce)
+	r, rok := stringOrStringer(right, coerce)
+	if !lok || !rok {
+		return "", "", false
+	}
+
+	lstring := reflect.ValueOf(left).Kind() == reflect.String
+	rstring := reflect.ValueOf(right).Kind() == reflect.String
+	if lstring == rstring {
+		return "", "", false
+	}
+
+	return l, r, true
+}
+
+// stringOrStringer returns v if it is a string, or the result of its String method if it implements fmt.Stringer.
+// If coerce is true, integers and bools are converted to their string forms as well.
+func stringOrStringer(v interface{}, coerce bool) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.String {
+		return value.String(), true
+	}
+
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String(), true
+	}
+
+	if !coerce {
+		return "", false
+	}
+
+	if value.Kind() == reflect.Bool {
+		return strconv.FormatBool(value.Bool()), true
+	}
+
+	if isIntKind(value.Kind()) {
+		i, err := ToInt64(v)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatInt(i, 10), true
+	}
+
+	return "", false
+}
+
+func (ev *Evaluator) evalInfixExpressionOperatorFuncs(l interface{}, r interface{}, op string) (interface{}, bool, error) {
+	for _, fn := range ev.operatorFuncs[op] {
+		o, err := fn(l, r)
+		if errors.Is(err, ErrOperatorNotHandled) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return o, true, nil
+	}
+
+	return nil, false, nil
+}
 
+func evalNilInfixExpression(l interface{}, r interface{}, op string, line int, col int) (interface{}, error) {
+	switch op {
+	case "==":
+		return l == nil && r == nil, nil
+	case "!=":
+		return l != nil || r != nil, nil
 	default:
-		return nil, newEvalErrorf(i.StartLine, i.StartCol, "cannot handle expression types in '%s' infix expression: %T vs %T", i.Operator, left, right)
+		return nil, newEvalErrorf(line, col, "cannot handle expression types in '%s' infix expression: %T vs %T", op, l, r)
 	}
 }
 
@@ -96,6 +215,8 @@ func evalBoolInfixExpression(l bool, r bool, op string, line int, col int) (inte
 		return l || r, nil
 	case "&&":
 		return l && r, nil
+	case "<", "<=", ">", ">=":
+		return nil, newEvalErrorf(line, col, "booleans cannot be ordered: %s", op)
 	default:
 		return nil, newEvalErrorf(line, col, "unexpected operator in bool infix expression: %s", op)
 	}
@@ -136,6 +257,24 @@ func evalIntInfixExpression(l int64, r int64, op string, line int, col int) (int
 	}
 }
 
+// evalEuclideanMod returns the Euclidean modulo of l and r, which is never negative.
+func evalEuclideanMod(l int64, r int64, line int, col int) (interface{}, error) {
+	if r == 0 {
+		return nil, newEvalErrorf(line, col, "division by zero")
+	}
+
+	m := l % r
+	if m < 0 {
+		if r < 0 {
+			m -= r
+		} else {
+			m += r
+		}
+	}
+
+	return m, nil
+}
+
 func evalStringInfixExpression(l string, r string, op string, line int, col int) (interface{}, error) {
 	switch op {
 	case "==":
@@ -14,6 +14,9 @@ func (ev *Evaluator) evalPrefixExpression(p ast.PrefixExpression) (interface{},
 	case "-":
 		return evalMinusPrefix(v, p.StartLine, p.StartCol)
 
+	case "+":
+		return evalPlusPrefix(v, p.StartLine, p.StartCol)
+
 	case "!":
 		return evalBangPrefix(v, p.StartLine, p.StartCol)
 
@@ -23,13 +26,21 @@ func (ev *Evaluator) evalPrefixExpression(p ast.PrefixExpression) (interface{},
 }
 
 func evalMinusPrefix(right interface{}, line int, col int) (interface{}, error) {
-	r, err := toInt64(right)
+	r, err := ToInt64(right)
 	if err != nil {
 		return nil, newEvalErrorf(line, col, "incompatible expression type for '-' prefix expression: %T", right)
 	}
 	return -r, nil
 }
 
+func evalPlusPrefix(right interface{}, line int, col int) (interface{}, error) {
+	r, err := ToInt64(right)
+	if err != nil {
+		return nil, newEvalErrorf(line, col, "incompatible expression type for '+' prefix expression: %T", right)
+	}
+	return r, nil
+}
+
 func evalBangPrefix(right interface{}, line int, col int) (interface{}, error) {
 	r, err := toBool(right)
 	if err != nil {
@@ -82,12 +82,20 @@ func (ev *Evaluator) evalStatement(st ast.Statement) (interface{}, error) {
 		return ev.evalExpressionStatement(*stmt)
 	case *ast.LetStatement:
 		return nil, ev.evalLetStatement(*stmt)
+	case *ast.AssignStatement:
+		return nil, ev.evalAssignStatement(*stmt)
 	case *ast.BreakStatement:
 		ev.evalBreakStatement()
 		return nil, nil
 	case *ast.ContinueStatement:
 		ev.evalContinueStatement()
 		return nil, nil
+	case *ast.WhitespaceStatement:
+		// already applied by the parser
+		return nil, nil
+	case *ast.ExtendsStatement:
+		// resolved by the template renderer
+		return nil, nil
 	default:
 		panic(newEvalErrorf(st.Line(), st.Col(), "unknown statement type: %T", st))
 	}
@@ -103,6 +111,9 @@ func (ev *Evaluator) evalLetStatement(l ast.LetStatement) error {
 		return err
 	}
 	name := l.Ident.Name
+	if ev.scope.IsReadOnly(name) {
+		return newEvalErrorf(l.Ident.StartLine, l.Ident.StartCol, "cannot assign to read-only identifier: %s", name)
+	}
 	ev.scope.Set(name, o)
 	return nil
 }
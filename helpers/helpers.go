@@ -1,32 +1,109 @@
 package helpers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/blizzy78/copper/evaluator"
+	"github.com/blizzy78/copper/ranger"
 	"github.com/blizzy78/copper/scope"
 	"github.com/blizzy78/copper/template"
 )
 
-var errUnsupportedTypeOrNil = errors.New("unsupported type or nil")
+var (
+	errUnsupportedTypeOrNil = errors.New("unsupported type or nil")
+	errNoMarkdownRenderer   = errors.New("no Markdown renderer set")
+
+	markdownRenderer func(s string) (string, error)
+	trustedHook      func(s string)
+)
+
+// DefaultFuncs returns the standard set of functions for use in templates, keyed by the names under which templates
+// usually call them. A new map is returned on every call, so it may be modified freely before passing it to
+// template.WithFuncMap.
+//
+// The set does not include Len, so that templates use the builtin len function, which also supports maps,
+// counts the runes of strings, and does not panic.
+func DefaultFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"safe":      Safe,
+		"html":      HTML,
+		"has":       Has,
+		"hasPrefix": HasPrefix,
+		"hasSuffix": HasSuffix,
+		"range":     ranger.NewInt,
+		"fromTo":    ranger.NewFromTo,
+	}
+}
 
 // Safe converts v to a string and returns it as a safe string.
 func Safe(v interface{}) template.SafeString {
 	return template.SafeString(toString(v))
 }
 
+// Trusted converts v to a string and returns it as a safe string, without escaping it. It is identical to Safe,
+// but signals that v is trusted to be safe for output, such as HTML from a trusted source. If a hook function
+// has been set using SetTrustedHook, it is called with the string, for example to log its use.
+func Trusted(v interface{}) template.SafeString {
+	s := toString(v)
+	if trustedHook != nil {
+		trustedHook(s)
+	}
+	return template.SafeString(s)
+}
+
+// SetTrustedHook sets a function that is called by Trusted for every string it returns. This may be used
+// to keep an audit trail of trusted output. SetTrustedHook should be called before any templates are rendered.
+func SetTrustedHook(h func(s string)) {
+	trustedHook = h
+}
+
 // HTML converts v to a string, escapes any special characters for HTML-safe output, and returns
 // it as a safe string.
 func HTML(v interface{}) template.SafeString {
 	return template.SafeString(html.EscapeString(toString(v)))
 }
 
+// Nl2br converts v to a string, escapes any special characters for HTML-safe output like HTML does, and
+// inserts a "<br>" tag before every line break. It returns the result as a safe string.
+func Nl2br(v interface{}) template.SafeString {
+	return template.SafeString(strings.ReplaceAll(html.EscapeString(toString(v)), "\n", "<br>\n"))
+}
+
+// SetMarkdownRenderer sets the function used by Markdown to render Markdown to HTML. The function must
+// return sanitized HTML that is safe for output. SetMarkdownRenderer should be called before any templates
+// are rendered.
+func SetMarkdownRenderer(r func(s string) (string, error)) {
+	markdownRenderer = r
+}
+
+// Markdown converts v to a string, renders it from Markdown to HTML using the function set with
+// SetMarkdownRenderer, and returns the HTML as a safe string. Markdown returns an error if no renderer
+// function has been set.
+func Markdown(v interface{}) (template.SafeString, error) {
+	if markdownRenderer == nil {
+		return "", errNoMarkdownRenderer
+	}
+
+	h, err := markdownRenderer(toString(v))
+	if err != nil {
+		return "", err
+	}
+
+	return template.SafeString(h), nil
+}
+
 // Len returns the length of v. If v is a string, slice, or array, it returns len(v).
 // Len panics if v is neither of those types, or if v is nil.
+//
+// Templates can use the builtin len function instead, which does not panic.
 func Len(v interface{}) int {
 	if v == nil {
 		panic(errUnsupportedTypeOrNil)
@@ -57,6 +134,726 @@ func HasSuffix(s string, w string) bool {
 	return strings.HasSuffix(s, w)
 }
 
+// Contains returns whether haystack contains needle. If haystack is a string, it returns whether needle,
+// converted to a string, is a substring of it, or false if needle is nil. If haystack is a slice or array,
+// it returns whether any of its elements is equal to needle. If haystack is a map, it returns whether any of
+// its keys is equal to needle. Integers of different types are equal if they have the same value. Contains
+// returns false if haystack is nil. Contains panics if haystack is neither of those types.
+func Contains(haystack interface{}, needle interface{}) bool {
+	if haystack == nil {
+		return false
+	}
+
+	value := reflect.ValueOf(haystack)
+
+	switch value.Kind() {
+	case reflect.String:
+		return needle != nil && strings.Contains(value.String(), toString(needle))
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if equal(value.Index(i).Interface(), needle) {
+				return true
+			}
+		}
+		return false
+
+	case reflect.Map:
+		for _, k := range value.MapKeys() {
+			if equal(k.Interface(), needle) {
+				return true
+			}
+		}
+		return false
+
+	default:
+		panic(errUnsupportedTypeOrNil)
+	}
+}
+
+// equal returns whether a and b are equal. Integers of different types are equal if they have the same value.
+// Other values are compared using reflect.DeepEqual.
+func equal(a interface{}, b interface{}) bool {
+	if ai, err := evaluator.ToInt64(a); err == nil {
+		bi, err := evaluator.ToInt64(b)
+		return err == nil && ai == bi
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// Upper converts v to a string and returns it with all Unicode letters mapped to their upper case.
+func Upper(v interface{}) string {
+	return strings.ToUpper(toString(v))
+}
+
+// Lower converts v to a string and returns it with all Unicode letters mapped to their lower case.
+func Lower(v interface{}) string {
+	return strings.ToLower(toString(v))
+}
+
+// Title converts v to a string and returns it with the first letter of each word mapped to its title case,
+// using strings.Title. Words are delimited by whitespace and punctuation only, so Title does not handle
+// Unicode punctuation or language-specific rules properly.
+func Title(v interface{}) string {
+	return strings.Title(toString(v)) //nolint:staticcheck
+}
+
+// TrimSpace converts v to a string and returns it with all leading and trailing white space removed.
+func TrimSpace(v interface{}) string {
+	return strings.TrimSpace(toString(v))
+}
+
+// Trim converts v to a string and returns it with all leading and trailing characters contained in cutset removed.
+func Trim(v interface{}, cutset string) string {
+	return strings.Trim(toString(v), cutset)
+}
+
+// Join converts the elements of v to strings and concatenates them, placing sep between them. v may be
+// a []interface{} or a []string. If v is of any other type, it is converted to a string as is.
+func Join(sep string, v interface{}) string {
+	if s, ok := join(v, sep); ok {
+		return s
+	}
+	return toString(v)
+}
+
+// Split is equivalent to calling strings.Split(s, sep).
+func Split(s string, sep string) []string {
+	return strings.Split(s, sep)
+}
+
+// Replace is equivalent to calling strings.ReplaceAll(s, old, new).
+func Replace(s string, old string, new string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+// Repeat is equivalent to calling strings.Repeat(s, count), except that a negative count is treated as zero
+// rather than causing a panic.
+func Repeat(s string, count int) string {
+	if count < 0 {
+		count = 0
+	}
+	return strings.Repeat(s, count)
+}
+
+// Default returns value if it is present, otherwise it returns fallback. A value is not present if it is nil
+// (including nil pointers), or if it is an empty string, slice, array, or map.
+func Default(fallback interface{}, value interface{}) interface{} {
+	if isEmpty(value) {
+		return fallback
+	}
+	return value
+}
+
+// isEmpty returns whether v is nil (including nil pointers), or an empty string, slice, array, or map.
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len() == 0
+	default:
+		return false
+	}
+}
+
+// Format is equivalent to calling fmt.Sprintf(format, args...). The result is returned as a regular string,
+// not as a safe string, so that it must still be escaped or marked as safe for output.
+func Format(format string, args ...interface{}) string {
+	return fmt.Sprintf(format, args...)
+}
+
+// Cycle returns one of vals, depending on the index of the loop iteration status. The values are cycled
+// through in order, starting over after the last value. If vals is empty, Cycle returns nil.
+// This is useful to alternate between values in a loop, for example CSS classes for "zebra" tables.
+func Cycle(status ranger.Status, vals ...interface{}) interface{} {
+	if len(vals) == 0 {
+		return nil
+	}
+	return vals[status.Index%len(vals)]
+}
+
+// Unique returns the elements of the slice or array v with duplicates removed, in the order in which they
+// first appear in v. Elements are compared using reflect.DeepEqual.
+// Unique panics if v is neither a slice nor an array, or if v is nil.
+func Unique(v interface{}) []interface{} {
+	if v == nil {
+		panic(errUnsupportedTypeOrNil)
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		panic(errUnsupportedTypeOrNil)
+	}
+
+	u := []interface{}{}
+
+outer:
+	for i := 0; i < value.Len(); i++ {
+		el := value.Index(i).Interface()
+
+		for _, e := range u {
+			if reflect.DeepEqual(e, el) {
+				continue outer
+			}
+		}
+
+		u = append(u, el)
+	}
+
+	return u
+}
+
+// First returns the first element of the slice or array v, or nil if v is empty or nil.
+// First panics if v is neither a slice nor an array.
+func First(v interface{}) interface{} {
+	value, ok := sequenceValue(v)
+	if !ok || value.Len() == 0 {
+		return nil
+	}
+	return value.Index(0).Interface()
+}
+
+// Last returns the last element of the slice or array v, or nil if v is empty or nil.
+// Last panics if v is neither a slice nor an array.
+func Last(v interface{}) interface{} {
+	value, ok := sequenceValue(v)
+	if !ok || value.Len() == 0 {
+		return nil
+	}
+	return value.Index(value.Len() - 1).Interface()
+}
+
+// Reverse returns a new slice containing the elements of the slice or array v in reverse order.
+// v itself is not modified. Reverse returns an empty slice if v is empty or nil.
+// Reverse panics if v is neither a slice nor an array.
+func Reverse(v interface{}) []interface{} {
+	r := []interface{}{}
+
+	value, ok := sequenceValue(v)
+	if !ok {
+		return r
+	}
+
+	for i := value.Len() - 1; i >= 0; i-- {
+		r = append(r, value.Index(i).Interface())
+	}
+
+	return r
+}
+
+// Sort returns a new slice containing the elements of the slice or array v in ascending order.
+// The elements must either all be integers, which are compared numerically, or all be strings, which are
+// compared lexically. Sort returns an error if v contains elements of mixed or other types, including nil.
+// v itself is not modified. Sort returns an empty slice if v is empty or nil.
+// Sort panics if v is neither a slice nor an array.
+func Sort(v interface{}) ([]interface{}, error) {
+	return sortByKeys(v, func(el interface{}) (interface{}, error) {
+		return el, nil
+	})
+}
+
+// SortBy returns a new slice containing the elements of the slice or array v in ascending order of their
+// field (for structs or pointers to structs) or entry (for maps) named key. The keys are compared following
+// the same rules as for Sort. SortBy returns an error if an element does not have such a field or entry.
+// Elements with equal keys retain their original order. v itself is not modified.
+// SortBy returns an empty slice if v is empty or nil.
+// SortBy panics if v is neither a slice nor an array.
+func SortBy(v interface{}, key string) ([]interface{}, error) {
+	return sortByKeys(v, func(el interface{}) (interface{}, error) {
+		return fieldOrEntry(el, key)
+	})
+}
+
+// sortByKeys returns a new slice containing the elements of the slice or array v, sorted by the keys
+// returned by keyFunc for each element.
+func sortByKeys(v interface{}, keyFunc func(el interface{}) (interface{}, error)) ([]interface{}, error) {
+	value, ok := sequenceValue(v)
+	if !ok {
+		return []interface{}{}, nil
+	}
+
+	els := make([]interface{}, value.Len())
+	keys := make([]interface{}, value.Len())
+
+	for i := range els {
+		els[i] = value.Index(i).Interface()
+
+		k, err := keyFunc(els[i])
+		if err != nil {
+			return nil, err
+		}
+
+		keys[i] = k
+	}
+
+	less, err := lessFunc(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := make([]int, len(els))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	sort.SliceStable(idx, func(a int, b int) bool {
+		return less(idx[a], idx[b])
+	})
+
+	sorted := make([]interface{}, len(els))
+	for i, j := range idx {
+		sorted[i] = els[j]
+	}
+
+	return sorted, nil
+}
+
+// lessFunc returns a function that reports whether the key at index a sorts before the key at index b.
+// keys must either all be integers or all be strings.
+func lessFunc(keys []interface{}) (func(a int, b int) bool, error) {
+	if len(keys) == 0 {
+		return func(a int, b int) bool { return false }, nil
+	}
+
+	if _, err := evaluator.ToInt64(keys[0]); err == nil {
+		ns := make([]int64, len(keys))
+		for i, k := range keys {
+			n, err := evaluator.ToInt64(k)
+			if err != nil {
+				return nil, fmt.Errorf("cannot sort mixed types: %T and %T", keys[0], k)
+			}
+			ns[i] = n
+		}
+
+		return func(a int, b int) bool { return ns[a] < ns[b] }, nil
+	}
+
+	if keys[0] != nil && reflect.ValueOf(keys[0]).Kind() == reflect.String {
+		ss := make([]string, len(keys))
+		for i, k := range keys {
+			if k == nil || reflect.ValueOf(k).Kind() != reflect.String {
+				return nil, fmt.Errorf("cannot sort mixed types: %T and %T", keys[0], k)
+			}
+			ss[i] = reflect.ValueOf(k).String()
+		}
+
+		return func(a int, b int) bool { return ss[a] < ss[b] }, nil
+	}
+
+	return nil, fmt.Errorf("cannot sort values of type %T", keys[0])
+}
+
+// fieldOrEntry returns the field named name of the struct or pointer to struct v, or the entry with key name
+// of the map v.
+func fieldOrEntry(v interface{}, name string) (interface{}, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+
+	switch value.Kind() {
+	case reflect.Struct:
+		f := value.FieldByName(name)
+		if !f.IsValid() || !f.CanInterface() {
+			return nil, fmt.Errorf("no such field in %T: %s", v, name)
+		}
+		return f.Interface(), nil
+
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map keys are not strings: %T", v)
+		}
+
+		e := value.MapIndex(reflect.ValueOf(name).Convert(value.Type().Key()))
+		if !e.IsValid() {
+			return nil, fmt.Errorf("no such entry in %T: %s", v, name)
+		}
+		return e.Interface(), nil
+
+	default:
+		return nil, fmt.Errorf("cannot get field of type %T: %s", v, name)
+	}
+}
+
+// sequenceValue returns the value of the slice or array v. It returns false if v is nil.
+// sequenceValue panics if v is neither a slice nor an array.
+func sequenceValue(v interface{}) (reflect.Value, bool) {
+	if v == nil {
+		return reflect.Value{}, false
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		panic(errUnsupportedTypeOrNil)
+	}
+
+	return value, true
+}
+
+// Sum returns the sum of the integer elements of the slice or array v. Elements that are not integers, such as
+// nil or strings, are skipped. Sum returns 0 if v is empty or nil.
+// Sum panics if v is neither a slice nor an array.
+func Sum(v interface{}) int64 {
+	sum := int64(0)
+	for _, i := range ints(v) {
+		sum += i
+	}
+	return sum
+}
+
+// Min returns the smallest of the integer elements of the slice or array v. Elements that are not integers, such as
+// nil or strings, are skipped. Min returns 0 if v is nil or does not contain any integers.
+// Min panics if v is neither a slice nor an array.
+func Min(v interface{}) int64 {
+	ns := ints(v)
+	if len(ns) == 0 {
+		return 0
+	}
+
+	min := ns[0]
+	for _, i := range ns[1:] {
+		if i < min {
+			min = i
+		}
+	}
+	return min
+}
+
+// Max returns the largest of the integer elements of the slice or array v. Elements that are not integers, such as
+// nil or strings, are skipped. Max returns 0 if v is nil or does not contain any integers.
+// Max panics if v is neither a slice nor an array.
+func Max(v interface{}) int64 {
+	ns := ints(v)
+	if len(ns) == 0 {
+		return 0
+	}
+
+	max := ns[0]
+	for _, i := range ns[1:] {
+		if i > max {
+			max = i
+		}
+	}
+	return max
+}
+
+// ints returns the integer elements of the slice or array v as int64, skipping all other elements.
+// ints panics if v is neither a slice nor an array.
+func ints(v interface{}) []int64 {
+	value, ok := sequenceValue(v)
+	if !ok {
+		return nil
+	}
+
+	ns := []int64{}
+	for i := 0; i < value.Len(); i++ {
+		if el, err := evaluator.ToInt64(value.Index(i).Interface()); err == nil {
+			ns = append(ns, el)
+		}
+	}
+	return ns
+}
+
+// Dict returns a map built from pairs, which must be alternating keys and values, such as
+// Dict("a", 1, "b", 2). Dict returns an error if pairs has an odd number of elements, or if a key is not a string.
+// If a key appears more than once, the last value is used.
+func Dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("odd number of arguments, expected key/value pairs: %d", len(pairs))
+	}
+
+	m := make(map[string]interface{}, len(pairs)/2)
+
+	for i := 0; i < len(pairs); i += 2 {
+		k, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("key #%d is not a string: %T", i/2, pairs[i])
+		}
+
+		m[k] = pairs[i+1]
+	}
+
+	return m, nil
+}
+
+// List returns items as a slice.
+func List(items ...interface{}) []interface{} {
+	if items == nil {
+		return []interface{}{}
+	}
+	return items
+}
+
+// Wordwrap converts v to a string and wraps it at word boundaries, so that no line is longer than width
+// characters (runes). Words are never split, so a single word longer than width remains on a line of its own.
+// Existing line breaks are preserved, while other runs of whitespace between words are collapsed into a single
+// space. If width is less than 1, the string is returned unchanged.
+func Wordwrap(v interface{}, width int) string {
+	s := toString(v)
+	if width < 1 {
+		return s
+	}
+
+	lines := strings.Split(s, "\n")
+
+	for i, line := range lines {
+		buf := strings.Builder{}
+		lineLen := 0
+
+		for _, word := range strings.Fields(line) {
+			wordLen := utf8.RuneCountInString(word)
+
+			if lineLen > 0 {
+				if lineLen+1+wordLen > width {
+					buf.WriteByte('\n')
+					lineLen = 0
+				} else {
+					buf.WriteByte(' ')
+					lineLen++
+				}
+			}
+
+			buf.WriteString(word)
+			lineLen += wordLen
+		}
+
+		lines[i] = buf.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Truncate converts v to a string and returns it unchanged if it is no longer than max characters (runes).
+// Otherwise, it is truncated to max characters, and suffix is appended, such as "…". If max is negative,
+// it is treated as 0.
+func Truncate(v interface{}, max int, suffix string) string {
+	if max < 0 {
+		max = 0
+	}
+
+	s := toString(v)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	return string([]rune(s)[:max]) + suffix
+}
+
+// JSON encodes v as JSON and returns it as a safe string. The characters <, >, and & are escaped, so that
+// the output can be used safely inside HTML script tags.
+func JSON(v interface{}) (template.SafeString, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return template.SafeString(b), nil
+}
+
+// ToJSONIndent encodes v as JSON, indenting nested values with indent, and returns it as a safe string.
+// The characters <, >, and & are escaped, so that the output can be used safely inside HTML script tags.
+func ToJSONIndent(v interface{}, indent string) (template.SafeString, error) {
+	b, err := json.MarshalIndent(v, "", indent)
+	if err != nil {
+		return "", err
+	}
+	return template.SafeString(b), nil
+}
+
+// Dump returns a compact, human-readable representation of v for debugging, escaped for HTML-safe output.
+// Map entries are sorted by key, slice and array elements are prefixed by their index, and exported struct
+// fields are listed in order of declaration. Pointers are followed, and strings are quoted.
+//
+// A pointer, map, or slice that refers back to a value that is currently being dumped is output as "<cycle>",
+// and values nested deeper than a fixed maximum depth are output as "...".
+func Dump(v interface{}) template.SafeString {
+	buf := strings.Builder{}
+	dump(&buf, reflect.ValueOf(v), map[dumpRef]struct{}{}, 0)
+	return template.SafeString(html.EscapeString(buf.String()))
+}
+
+// dumpMaxDepth is the maximum nesting depth of values output by Dump.
+const dumpMaxDepth = 32
+
+// dumpRef identifies a pointer, map, or slice by address and type.
+type dumpRef struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func dump(buf *strings.Builder, value reflect.Value, visiting map[dumpRef]struct{}, depth int) {
+	if !value.IsValid() {
+		buf.WriteString("nil")
+		return
+	}
+
+	if depth > dumpMaxDepth {
+		buf.WriteString("...")
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			break
+		}
+
+		ref := dumpRef{ptr: value.Pointer(), typ: value.Type()}
+		if _, ok := visiting[ref]; ok {
+			buf.WriteString("<cycle>")
+			return
+		}
+
+		visiting[ref] = struct{}{}
+		defer delete(visiting, ref)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		dump(buf, value.Elem(), visiting, depth+1)
+
+	case reflect.String:
+		buf.WriteString(strconv.Quote(value.String()))
+
+	case reflect.Slice, reflect.Array:
+		buf.WriteByte('[')
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Itoa(i))
+			buf.WriteString(": ")
+			dump(buf, value.Index(i), visiting, depth+1)
+		}
+		buf.WriteByte(']')
+
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(a int, b int) bool {
+			return dumpKeyLess(keys[a], keys[b])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			dump(buf, k, visiting, depth+1)
+			buf.WriteString(": ")
+			dump(buf, value.MapIndex(k), visiting, depth+1)
+		}
+		buf.WriteByte('}')
+
+	case reflect.Struct:
+		t := value.Type()
+		buf.WriteString(t.String())
+		buf.WriteByte('{')
+		n := 0
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if n > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(f.Name)
+			buf.WriteString(": ")
+			dump(buf, value.Field(i), visiting, depth+1)
+			n++
+		}
+		buf.WriteByte('}')
+
+	case reflect.Func, reflect.Chan:
+		buf.WriteString(value.Type().String())
+
+	default:
+		buf.WriteString(fmt.Sprint(value.Interface()))
+	}
+}
+
+// dumpKeyLess reports whether the map key a sorts before the map key b. Numbers sort before strings, which sort
+// before keys of any other kind. Numbers are compared numerically, strings lexically, and keys of any other kind
+// by their fmt.Sprint representation.
+func dumpKeyLess(a reflect.Value, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	if ra, rb := dumpKeyRank(a.Kind()), dumpKeyRank(b.Kind()); ra != rb {
+		return ra < rb
+	}
+
+	switch {
+	case isIntKind(a.Kind()) && isIntKind(b.Kind()):
+		return a.Int() < b.Int()
+	case isUintKind(a.Kind()) && isUintKind(b.Kind()):
+		return a.Uint() < b.Uint()
+	case dumpKeyRank(a.Kind()) == 0:
+		return toFloat64(a) < toFloat64(b)
+	case a.Kind() == reflect.String:
+		return a.String() < b.String()
+	default:
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+}
+
+// dumpKeyRank returns 0 for numeric kinds, 1 for strings, and 2 for all other kinds.
+func dumpKeyRank(k reflect.Kind) int {
+	switch {
+	case isIntKind(k), isUintKind(k), isFloatKind(k):
+		return 0
+	case k == reflect.String:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// toFloat64 returns the value of the integer or float value v as float64.
+func toFloat64(v reflect.Value) float64 {
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int())
+	case isUintKind(v.Kind()):
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
 func toString(v interface{}) string { //nolint:gocyclo
 	if s, ok := v.(fmt.Stringer); ok {
 		return s.String()
@@ -92,19 +889,30 @@ func toString(v interface{}) string { //nolint:gocyclo
 		return strconv.FormatUint(uint64(value), 10)
 	case uint64:
 		return strconv.FormatUint(value, 10)
+	case []interface{}, []string:
+		s, _ := join(value, "")
+		return s
+	default:
+		return fmt.Sprintf("[?TYPE? %T]", v)
+	}
+}
+
+// join converts the elements of v to strings and concatenates them, placing sep between them. It returns false
+// if v is neither a []interface{} nor a []string.
+func join(v interface{}, sep string) (string, bool) {
+	switch value := v.(type) {
 	case []interface{}:
 		buf := strings.Builder{}
-		for _, el := range value {
-			_, _ = buf.WriteString(toString(el))
+		for i, el := range value {
+			if i > 0 {
+				buf.WriteString(sep)
+			}
+			buf.WriteString(toString(el))
 		}
-		return buf.String()
+		return buf.String(), true
 	case []string:
-		buf := strings.Builder{}
-		for _, es := range value {
-			buf.WriteString(es)
-		}
-		return buf.String()
+		return strings.Join(value, sep), true
 	default:
-		return fmt.Sprintf("[?TYPE? %T]", v)
+		return "", false
 	}
 }
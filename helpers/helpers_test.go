@@ -1,13 +1,64 @@
 package helpers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/matryer/is"
 
+	"github.com/blizzy78/copper/ranger"
 	"github.com/blizzy78/copper/scope"
+	"github.com/blizzy78/copper/template"
 )
 
+func TestDefaultFuncs(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<% for i in fromTo(1, 3) %><% i %><% end %>|<% for i in range(0, 2) %><% i %><% end %>|` +
+		`<% len(x) %>|<% has("x") %>|<% hasPrefix(x, "<") %>|<% hasSuffix(x, "!") %>|<% html(x) %>|<% safe(x) %>`
+
+	l := template.LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := template.NewRenderer(l,
+		template.WithFuncMap(DefaultFuncs()),
+		template.WithEscaperByExt(map[string]template.Escaper{
+			"": func(v interface{}) template.SafeString {
+				return Safe(v)
+			},
+		}),
+	)
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+		"x": "<b>",
+	})
+	is.NoErr(err)
+	is.Equal(res, "123|01|3|true|true|false|&lt;b&gt;|<b>")
+}
+
+func TestDefaultFuncs_Len(t *testing.T) {
+	is := is.New(t)
+
+	l := template.LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% safe(len(m)) %>|<% safe(len(s)) %>`)), nil
+	})
+
+	r := template.NewRenderer(l, template.WithFuncMap(DefaultFuncs()))
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+		"m": map[string]int{"a": 1, "b": 2},
+		"s": "äöü",
+	})
+	is.NoErr(err)
+	is.Equal(res, "2|3")
+}
+
 func TestSafe(t *testing.T) {
 	is := is.New(t)
 
@@ -28,6 +79,28 @@ func TestSafe(t *testing.T) {
 	}
 }
 
+func TestTrusted(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Trusted("<b>foo</b>"), template.SafeString("<b>foo</b>"))
+	is.Equal(Trusted(123), template.SafeString("123"))
+}
+
+func TestTrusted_Hook(t *testing.T) {
+	is := is.New(t)
+
+	defer SetTrustedHook(nil)
+
+	audit := []string{}
+	SetTrustedHook(func(s string) {
+		audit = append(audit, s)
+	})
+
+	is.Equal(Trusted("<b>foo</b>"), template.SafeString("<b>foo</b>"))
+	is.Equal(Trusted("<i>bar</i>"), template.SafeString("<i>bar</i>"))
+	is.Equal(audit, []string{"<b>foo</b>", "<i>bar</i>"})
+}
+
 func TestHTML(t *testing.T) {
 	is := is.New(t)
 
@@ -49,6 +122,35 @@ func TestHTML(t *testing.T) {
 	}
 }
 
+func TestNl2br(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Nl2br("foo"), template.SafeString("foo"))
+	is.Equal(Nl2br("<a>\nb\n"), template.SafeString("&lt;a&gt;<br>\nb<br>\n"))
+	is.Equal(Nl2br(nil), template.SafeString(""))
+}
+
+func TestMarkdown(t *testing.T) {
+	is := is.New(t)
+
+	defer SetMarkdownRenderer(nil)
+
+	SetMarkdownRenderer(func(s string) (string, error) {
+		return "<p>" + strings.TrimPrefix(s, "# ") + "</p>", nil
+	})
+
+	actual, err := Markdown("# foo")
+	is.NoErr(err)
+	is.Equal(actual, template.SafeString("<p>foo</p>"))
+}
+
+func TestMarkdown_NoRenderer(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Markdown("# foo")
+	is.True(errors.Is(err, errNoMarkdownRenderer))
+}
+
 func TestLen(t *testing.T) {
 	is := is.New(t)
 
@@ -70,6 +172,412 @@ func TestLen(t *testing.T) {
 	}
 }
 
+func TestLen_Condition(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<% if len(items) > 0 %>some<% else %>none<% end %> <% if count(items) > 0 %>some<% else %>none<% end %>`
+
+	r := template.NewRenderer(template.LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	}), template.WithScopeData("count", Len))
+
+	tests := []struct {
+		items    []string
+		expected string
+	}{
+		{[]string{}, "none none"},
+		{[]string{"a"}, "some some"},
+		{[]string{"a", "b"}, "some some"},
+	}
+
+	for _, test := range tests {
+		buf := strings.Builder{}
+		err := r.Render(context.Background(), &buf, "test", map[string]interface{}{
+			"items": test.items,
+		})
+		is.NoErr(err)
+		is.Equal(buf.String(), test.expected)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    interface{}
+		expected []interface{}
+	}{
+		{[]int{3, 1, 3, 2, 1}, []interface{}{3, 1, 2}},
+		{[]string{"b", "a", "b", "c", "a"}, []interface{}{"b", "a", "c"}},
+		{[3]string{"a", "a", "a"}, []interface{}{"a"}},
+		{[]interface{}{1, "1", 1, []int{1}, []int{1}}, []interface{}{1, "1", []int{1}}},
+		{[]int{}, []interface{}{}},
+	}
+
+	for _, test := range tests {
+		actual := Unique(test.input)
+		is.Equal(actual, test.expected)
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input         interface{}
+		expectedFirst interface{}
+		expectedLast  interface{}
+	}{
+		{[]int{1, 2, 3}, 1, 3},
+		{[]interface{}{"a", true}, "a", true},
+		{[1]string{"a"}, "a", "a"},
+		{[]int{}, nil, nil},
+		{[]string(nil), nil, nil},
+		{nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		is.Equal(First(test.input), test.expectedFirst)
+		is.Equal(Last(test.input), test.expectedLast)
+	}
+}
+
+func TestFirst_Panic(t *testing.T) {
+	is := is.New(t)
+
+	defer func() {
+		is.Equal(recover(), errUnsupportedTypeOrNil)
+	}()
+
+	First("foo")
+}
+
+func TestReverse(t *testing.T) {
+	is := is.New(t)
+
+	s := []int{1, 2, 3}
+	is.Equal(Reverse(s), []interface{}{3, 2, 1})
+	is.Equal(s, []int{1, 2, 3})
+
+	is.Equal(Reverse([2]string{"a", "b"}), []interface{}{"b", "a"})
+	is.Equal(Reverse([]int{}), []interface{}{})
+	is.Equal(Reverse(nil), []interface{}{})
+}
+
+func TestSort(t *testing.T) {
+	is := is.New(t)
+
+	ints := []int{3, 1, 2}
+	s, err := Sort(ints)
+	is.NoErr(err)
+	is.Equal(s, []interface{}{1, 2, 3})
+	is.Equal(ints, []int{3, 1, 2})
+
+	s, err = Sort([]string{"b", "c", "a"})
+	is.NoErr(err)
+	is.Equal(s, []interface{}{"a", "b", "c"})
+
+	s, err = Sort([]interface{}{int64(2), uint8(1)})
+	is.NoErr(err)
+	is.Equal(s, []interface{}{uint8(1), int64(2)})
+
+	s, err = Sort(nil)
+	is.NoErr(err)
+	is.Equal(s, []interface{}{})
+}
+
+func TestSort_Error(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Sort([]interface{}{1, "a"})
+	is.True(err != nil)
+
+	_, err = Sort([]interface{}{"a", nil})
+	is.True(err != nil)
+
+	_, err = Sort([]bool{true, false})
+	is.True(err != nil)
+}
+
+func TestSortBy(t *testing.T) {
+	is := is.New(t)
+
+	type user struct {
+		Name string
+		Age  int
+	}
+
+	users := []user{{"b", 30}, {"a", 20}, {"c", 20}}
+
+	s, err := SortBy(users, "Age")
+	is.NoErr(err)
+	is.Equal(s, []interface{}{user{"a", 20}, user{"c", 20}, user{"b", 30}})
+
+	s, err = SortBy([]*user{&users[0], &users[1]}, "Name")
+	is.NoErr(err)
+	is.Equal(s, []interface{}{&users[1], &users[0]})
+
+	s, err = SortBy([]map[string]interface{}{{"x": "b"}, {"x": "a"}}, "x")
+	is.NoErr(err)
+	is.Equal(s, []interface{}{map[string]interface{}{"x": "a"}, map[string]interface{}{"x": "b"}})
+
+	_, err = SortBy(users, "Foo")
+	is.True(err != nil)
+}
+
+func TestSumMinMax(t *testing.T) {
+	is := is.New(t)
+
+	type myInt int
+
+	tests := []struct {
+		input       interface{}
+		expectedSum int64
+		expectedMin int64
+		expectedMax int64
+	}{
+		{[]int{3, -1, 5}, 7, -1, 5},
+		{[3]int64{10, 20, 30}, 60, 10, 30},
+		{[]interface{}{"a", 4, nil, uint8(2), myInt(-3), true}, 3, -3, 4},
+		{[]interface{}{"a", nil}, 0, 0, 0},
+		{[]int{}, 0, 0, 0},
+		{nil, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		is.Equal(Sum(test.input), test.expectedSum)
+		is.Equal(Min(test.input), test.expectedMin)
+		is.Equal(Max(test.input), test.expectedMax)
+	}
+}
+
+func TestDict(t *testing.T) {
+	is := is.New(t)
+
+	m, err := Dict("a", 1, "b", "x", "a", true)
+	is.NoErr(err)
+	is.Equal(m, map[string]interface{}{
+		"a": true,
+		"b": "x",
+	})
+
+	m, err = Dict()
+	is.NoErr(err)
+	is.Equal(m, map[string]interface{}{})
+}
+
+func TestDict_Error(t *testing.T) {
+	is := is.New(t)
+
+	_, err := Dict("a", 1, "b")
+	is.Equal(err.Error(), "odd number of arguments, expected key/value pairs: 3")
+
+	_, err = Dict("a", 1, 2, 3)
+	is.Equal(err.Error(), "key #1 is not a string: int")
+}
+
+func TestList(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(List(1, "a", nil), []interface{}{1, "a", nil})
+	is.Equal(List(), []interface{}{})
+}
+
+func TestDictList_Template(t *testing.T) {
+	is := is.New(t)
+
+	s := scope.Scope{}
+	s.Set("dict", Dict)
+	s.Set("list", List)
+	s.Set("join", Join)
+	s.Set("safe", Safe)
+
+	res, err := template.RenderString(`<% let d = dict("items", list(1, "a", true)) %><% safe(join(",", d.items)) %>`, nil, &s)
+	is.NoErr(err)
+	is.Equal(res, "1,a,true")
+}
+
+func TestWordwrap(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    interface{}
+		width    int
+		expected string
+	}{
+		{"the quick brown fox jumps over the lazy dog", 10, "the quick\nbrown fox\njumps over\nthe lazy\ndog"},
+		{"the quick brown fox", 100, "the quick brown fox"},
+		{"a verylongword b", 4, "a\nverylongword\nb"},
+		{"first line\nsecond line here", 11, "first line\nsecond line\nhere"},
+		{"äöü äöü äöü", 7, "äöü äöü\näöü"},
+		{"foo   bar", 0, "foo   bar"},
+		{"", 10, ""},
+		{123, 10, "123"},
+	}
+
+	for _, test := range tests {
+		actual := Wordwrap(test.input, test.width)
+		is.Equal(actual, test.expected)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    interface{}
+		max      int
+		expected string
+	}{
+		{"hello world", 5, "hello..."},
+		{"hello", 5, "hello"},
+		{"hello", 10, "hello"},
+		{"héllö wörld", 5, "héllö..."},
+		{"日本語のテキスト", 3, "日本語..."},
+		{"hello", 0, "..."},
+		{"hello", -1, "..."},
+		{"", 0, ""},
+		{123456, 3, "123..."},
+	}
+
+	for _, test := range tests {
+		is.Equal(Truncate(test.input, test.max, "..."), test.expected)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{map[string]interface{}{"b": []int{1, 2}, "a": nil}, `{"a":null,"b":[1,2]}`},
+		{[]interface{}{"x", 1, true}, `["x",1,true]`},
+		{"</script><b>&", `"\u003c/script\u003e\u003cb\u003e\u0026"`},
+	}
+
+	for _, test := range tests {
+		actual, err := JSON(test.input)
+		is.NoErr(err)
+		is.True(json.Valid([]byte(actual)))
+		is.Equal(actual, template.SafeString(test.expected))
+	}
+}
+
+func TestJSON_Error(t *testing.T) {
+	is := is.New(t)
+
+	_, err := JSON(func() {})
+	is.True(err != nil)
+}
+
+func TestToJSONIndent(t *testing.T) {
+	is := is.New(t)
+
+	v := map[string]interface{}{
+		"name": "</script><b>&",
+		"tags": []string{"a", "b"},
+		"nested": map[string]interface{}{
+			"x": 1,
+		},
+	}
+
+	expected := `{
+  "name": "\u003c/script\u003e\u003cb\u003e\u0026",
+  "nested": {
+    "x": 1
+  },
+  "tags": [
+    "a",
+    "b"
+  ]
+}`
+
+	actual, err := ToJSONIndent(v, "  ")
+	is.NoErr(err)
+	is.Equal(actual, template.SafeString(expected))
+}
+
+func TestToJSONIndent_Error(t *testing.T) {
+	is := is.New(t)
+
+	_, err := ToJSONIndent(func() {}, "  ")
+	is.True(err != nil)
+}
+
+func TestDump(t *testing.T) {
+	is := is.New(t)
+
+	type user struct {
+		Name  string
+		Admin bool
+		notes string //nolint:unused,structcheck
+	}
+
+	v := map[string]interface{}{
+		"users": []interface{}{
+			&user{Name: "<Jane>", Admin: true},
+			user{Name: "John"},
+		},
+		"counts": map[string]int{
+			"z": 3,
+			"a": 1,
+		},
+		"empty": nil,
+		"tags":  [2]string{"x", "y"},
+	}
+
+	expected := `{"counts": {"a": 1, "z": 3}, "empty": nil, "tags": [0: "x", 1: "y"], ` +
+		`"users": [0: helpers.user{Name: "<Jane>", Admin: true}, 1: helpers.user{Name: "John", Admin: false}]}`
+
+	for i := 0; i < 10; i++ {
+		is.Equal(Dump(v), template.SafeString(html.EscapeString(expected)))
+	}
+}
+
+func TestDump_IntKeys(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Dump(map[int]string{10: "c", 2: "b", -1: "a"}), template.SafeString(html.EscapeString(`{-1: "a", 2: "b", 10: "c"}`)))
+	is.Equal(Dump(map[uint8]int{10: 3, 2: 2, 1: 1}), template.SafeString("{1: 1, 2: 2, 10: 3}"))
+	is.Equal(Dump(map[interface{}]int{10: 3, 2: 2, "a": 1}), template.SafeString(html.EscapeString(`{2: 2, 10: 3, "a": 1}`)))
+	is.Equal(Dump(map[interface{}]int{10: 3, uint(2): 2, 1.5: 1}), template.SafeString("{1.5: 1, 2: 2, 10: 3}"))
+}
+
+func TestDump_Cycle(t *testing.T) {
+	is := is.New(t)
+
+	type node struct {
+		Name string
+		Next *node
+	}
+
+	n := &node{Name: "a"}
+	n.Next = n
+	is.Equal(Dump(n), template.SafeString(html.EscapeString(`helpers.node{Name: "a", Next: <cycle>}`)))
+
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	is.Equal(Dump(m), template.SafeString(html.EscapeString(`{"a": 1, "self": <cycle>}`)))
+
+	shared := []int{1}
+	is.Equal(Dump([]interface{}{shared, shared}), template.SafeString("[0: [0: 1], 1: [0: 1]]"))
+}
+
+func TestDump_MaxDepth(t *testing.T) {
+	is := is.New(t)
+
+	var v interface{} = 1
+	for i := 0; i < dumpMaxDepth+10; i++ {
+		v = []interface{}{v}
+	}
+
+	res := string(Dump(v))
+	is.True(strings.Contains(res, "[0: ...]"))
+	is.True(!strings.Contains(res, "1"))
+}
+
 func TestHas(t *testing.T) {
 	is := is.New(t)
 
@@ -79,3 +587,189 @@ func TestHas(t *testing.T) {
 	is.True(Has("foo", &s))
 	is.True(!Has("bar", &s))
 }
+
+func TestContains(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		haystack interface{}
+		needle   interface{}
+		expected bool
+	}{
+		{"foobar", "oba", true},
+		{"foobar", "baz", false},
+		{"a1b", 1, true},
+		{"foobar", nil, false},
+		{"", nil, false},
+		{[]string{"a", "b"}, "b", true},
+		{[]string{"a", "b"}, "c", false},
+		{[]int{1, 2, 3}, int64(2), true},
+		{[2]interface{}{"a", nil}, nil, true},
+		{map[string]int{"a": 1}, "a", true},
+		{map[string]int{"a": 1}, "b", false},
+		{map[int]string{1: "a"}, int64(1), true},
+		{nil, "a", false},
+	}
+
+	for _, test := range tests {
+		is.Equal(Contains(test.haystack, test.needle), test.expected)
+	}
+}
+
+func TestContains_Panic(t *testing.T) {
+	is := is.New(t)
+
+	defer func() {
+		is.Equal(recover(), errUnsupportedTypeOrNil)
+	}()
+
+	Contains(123, 1)
+}
+
+func TestUpperLowerTitle(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input         interface{}
+		expectedUpper string
+		expectedLower string
+		expectedTitle string
+	}{
+		{"hello World", "HELLO WORLD", "hello world", "Hello World"},
+		{"émile zola", "ÉMILE ZOLA", "émile zola", "Émile Zola"},
+		{123, "123", "123", "123"},
+		{true, "TRUE", "true", "True"},
+		{[]interface{}{"foo ", 1, " bar"}, "FOO 1 BAR", "foo 1 bar", "Foo 1 Bar"},
+		{nil, "", "", ""},
+	}
+
+	for _, test := range tests {
+		is.Equal(Upper(test.input), test.expectedUpper)
+		is.Equal(Lower(test.input), test.expectedLower)
+		is.Equal(Title(test.input), test.expectedTitle)
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(TrimSpace("  foo bar \n\t"), "foo bar")
+	is.Equal(TrimSpace(123), "123")
+}
+
+func TestTrim(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Trim("--foo-bar-+", "-+"), "foo-bar")
+	is.Equal(Trim(1221, "1"), "22")
+}
+
+func TestJoin(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{[]interface{}{"a", 1, true, nil, uint8(2), []string{"b", "c"}}, "a, 1, true, , 2, bc"},
+		{[]string{"a", "b", "c"}, "a, b, c"},
+		{[]string{}, ""},
+		{"foo", "foo"},
+		{123, "123"},
+	}
+
+	for _, test := range tests {
+		is.Equal(Join(", ", test.input), test.expected)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Split("a,b,,c", ","), []string{"a", "b", "", "c"})
+	is.Equal(Split("abc", ""), []string{"a", "b", "c"})
+}
+
+func TestReplace(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Replace("a-b-c", "-", "+"), "a+b+c")
+	is.Equal(Replace("abc", "x", "y"), "abc")
+}
+
+func TestRepeat(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Repeat("ab", 3), "ababab")
+	is.Equal(Repeat("ab", 0), "")
+	is.Equal(Repeat("ab", -1), "")
+}
+
+func TestDefault(t *testing.T) {
+	is := is.New(t)
+
+	var nilPtr *int
+
+	tests := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{nil, "fallback"},
+		{nilPtr, "fallback"},
+		{"", "fallback"},
+		{template.SafeString(""), "fallback"},
+		{[]interface{}{}, "fallback"},
+		{[]string(nil), "fallback"},
+		{map[string]interface{}{}, "fallback"},
+		{"foo", "foo"},
+		{0, 0},
+		{false, false},
+		{[]string{"a"}, []string{"a"}},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}},
+	}
+
+	for _, test := range tests {
+		is.Equal(Default("fallback", test.input), test.expected)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Format("%05d|%x|%-4s|%q|%.2f|%v|%%", 42, 255, "ab", "c", 1.5, true), `00042|ff|ab  |"c"|1.50|true|%`)
+	is.Equal(Format("no args"), "no args")
+}
+
+func TestFormat_Template(t *testing.T) {
+	is := is.New(t)
+
+	s := scope.Scope{}
+	s.Set("format", Format)
+	s.Set("safe", Safe)
+
+	res, err := template.RenderString(`<% safe(format("%05d-%s", n, "x")) %><% format("%d", n) %>`, map[string]interface{}{
+		"n": 42,
+	}, &s)
+	is.NoErr(err)
+	is.Equal(res, "00042-x!UNSAFE!") // plain string is not safe for output
+}
+
+func TestCycle(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		vals     []interface{}
+		expected []interface{}
+	}{
+		{[]interface{}{"odd", "even"}, []interface{}{"odd", "even", "odd", "even", "odd"}},
+		{[]interface{}{1, 2, 3}, []interface{}{1, 2, 3, 1, 2}},
+		{[]interface{}{}, []interface{}{nil, nil, nil, nil, nil}},
+	}
+
+	for _, test := range tests {
+		for i, expected := range test.expected {
+			actual := Cycle(ranger.Status{Index: i}, test.vals...)
+			is.Equal(actual, expected)
+		}
+	}
+}
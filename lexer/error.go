@@ -39,3 +39,7 @@ func (e parseError) Error() string {
 	}
 	return fmt.Sprintf("parse error at line %d, column %d: %v", e.line, e.col, e.err)
 }
+
+func (e parseError) Unwrap() error {
+	return e.err
+}
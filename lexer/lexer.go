@@ -12,12 +12,21 @@ import (
 type Lexer struct {
 	r              io.RuneReader
 	optStartInCode bool
+	optCodeLines   bool
+	inCodeLine     bool
+	atLineStart    bool
+	inCodeBlock    bool
+	codeBlockLine  int
+	codeBlockCol   int
 	line           int
 	col            int
 	currChar       rune
 	nextChar       rune
 	currEOF        bool
 	nextEOF        bool
+	doneCh         <-chan struct{}
+	errCh          chan error
+	done           bool
 }
 
 // Opt is the type of a function that configures an option of l.
@@ -26,6 +35,8 @@ type Opt func(l *Lexer)
 type stateFunc func(tCh chan<- *Token) stateFunc
 
 var (
+	errUnclosedCodeBlock = errors.New("unclosed code block")
+
 	keywords = map[string]TokenType{
 		"let":      Let,
 		"if":       If,
@@ -40,6 +51,11 @@ var (
 		"false":    False,
 		"nil":      Nil,
 		"capture":  Capture,
+		"do":       Do,
+		"switch":   Switch,
+		"case":     Case,
+		"block":    Block,
+		"extends":  Extends,
 	}
 )
 
@@ -64,6 +80,16 @@ func WithStartInCodeMode() Opt {
 	}
 }
 
+// WithCodeLines configures a lexer to treat lines starting with the '%' character as code lines when in
+// literal mode. A code line is lexed as code until the end of the line, as if it was wrapped in a code
+// block (<% %>). The line break ending the code line is not part of the output. Lines starting with "%>"
+// are not treated as code lines.
+func WithCodeLines() Opt {
+	return func(l *Lexer) {
+		l.optCodeLines = true
+	}
+}
+
 // Tokens reads from the lexer's input and writes a sequence of tokens into tCh. If an error occurs
 // when producing tokens, the error is associated with the next token in the channel. Token production
 // stops when there was an error, or when the done channel is closed.
@@ -71,6 +97,8 @@ func (l *Lexer) Tokens() (<-chan *Token, chan<- struct{}) {
 	tokenCh := make(chan *Token)
 	doneCh := make(chan struct{})
 
+	l.doneCh = doneCh
+
 	startState := l.parseLiteral
 	if l.optStartInCode {
 		startState = l.parseCode
@@ -81,9 +109,15 @@ func (l *Lexer) Tokens() (<-chan *Token, chan<- struct{}) {
 	}
 
 	go func(state stateFunc) {
-		defer close(tokenCh)
+		defer func() {
+			close(tokenCh)
+
+			if l.errCh != nil {
+				close(l.errCh)
+			}
+		}()
 
-		for state != nil {
+		for state != nil && !l.done {
 			if l.currEOF {
 				state = l.parseEOF
 			}
@@ -95,10 +129,43 @@ func (l *Lexer) Tokens() (<-chan *Token, chan<- struct{}) {
 	return tokenCh, doneCh
 }
 
+// TokensWithErrors works like Tokens, but delivers errors separately. If an error occurs when producing tokens,
+// including when an illegal token is found, it is sent into the error channel instead of the token channel,
+// and token production stops. Both channels are closed when token production stops. The error channel is
+// buffered, so it may be read after the token channel has been drained.
+func (l *Lexer) TokensWithErrors() (<-chan *Token, <-chan error, chan<- struct{}) {
+	l.errCh = make(chan error, 1)
+
+	tCh, doneCh := l.Tokens()
+
+	return tCh, l.errCh, doneCh
+}
+
+// Tokenize returns all tokens of src, lexed by a new lexer configured with opts. The tokens are read
+// until the end of input, including the final EOF token. If an error occurs, the tokens read so far
+// are returned together with the error.
+func Tokenize(src string, opts ...Opt) ([]Token, error) {
+	tCh, doneCh := New(strings.NewReader(src), opts...).Tokens()
+
+	defer close(doneCh)
+
+	toks := []Token{}
+
+	for tok := range tCh {
+		if tok.Err != nil {
+			return toks, tok.Err
+		}
+
+		toks = append(toks, *tok)
+	}
+
+	return toks, nil
+}
+
 func (l *Lexer) parseLiteral(tCh chan<- *Token) stateFunc {
 	buf := strings.Builder{}
 
-	defer emitTokenBuffer(tCh, Literal, &buf, l.line, l.col)
+	defer l.emitTokenBuffer(tCh, Literal, &buf, l.line, l.col)
 
 	for {
 		if l.currEOF {
@@ -109,6 +176,10 @@ func (l *Lexer) parseLiteral(tCh chan<- *Token) stateFunc {
 			return l.parseCodeStart
 		}
 
+		if l.isAtCodeLineStart() {
+			return l.parseCodeLineStart
+		}
+
 		if _, err := buf.WriteRune(l.currChar); err != nil {
 			return l.parseError(err, l.line, l.col)
 		}
@@ -120,23 +191,48 @@ func (l *Lexer) parseLiteral(tCh chan<- *Token) stateFunc {
 }
 
 func (l *Lexer) parseEOF(tCh chan<- *Token) stateFunc {
-	tCh <- newToken(EOF, "", l.line, l.col)
+	if l.inCodeBlock {
+		// report the position of the code block's start rather than the end of input
+		l.emit(tCh, newErrorToken(errUnclosedCodeBlock, l.codeBlockLine, l.codeBlockCol))
+		return nil
+	}
+
+	l.emit(tCh, newToken(EOF, "", l.line, l.col, l.line, l.col))
 	return nil
 }
 
 func (l *Lexer) parseCodeStart(tCh chan<- *Token) stateFunc {
+	l.inCodeBlock = true
+	l.codeBlockLine = l.line
+	l.codeBlockCol = l.col
 	return l.readNextCharsAndThen(2, l.parseCode)
 }
 
 func (l *Lexer) parseCodeEnd(tCh chan<- *Token) stateFunc {
+	l.inCodeLine = false
+	l.inCodeBlock = false
 	return l.readNextCharsAndThen(2, l.parseLiteral)
 }
 
+func (l *Lexer) parseCodeLineStart(tCh chan<- *Token) stateFunc {
+	l.inCodeLine = true
+	return l.readNextCharsAndThen(1, l.parseCode)
+}
+
+func (l *Lexer) parseCodeLineEnd(tCh chan<- *Token) stateFunc {
+	l.inCodeLine = false
+	return l.readNextCharsAndThen(1, l.parseLiteral)
+}
+
 func (l *Lexer) parseCode(tCh chan<- *Token) stateFunc { //nolint:gocyclo
 	if err := l.skipWhitespace(); err != nil {
 		return l.parseError(err, l.line, l.col)
 	}
 
+	if l.inCodeLine && !l.currEOF && l.currChar == '\n' {
+		return l.parseCodeLineEnd
+	}
+
 	if isIntChar(l.currChar) {
 		return l.parseInt
 	}
@@ -151,11 +247,11 @@ func (l *Lexer) parseCode(tCh chan<- *Token) stateFunc { //nolint:gocyclo
 	case '=':
 		return l.parseAssignOrEqual
 	case '+':
-		return l.parseToken(Plus, "+")
+		return l.parseOperatorOrAssign(Plus, PlusAssign, "+")
 	case '-':
-		return l.parseToken(Minus, "-")
+		return l.parseOperatorOrAssign(Minus, MinusAssign, "-")
 	case '*':
-		return l.parseToken(Asterisk, "*")
+		return l.parseOperatorOrAssign(Asterisk, AsteriskAssign, "*")
 	case '/':
 		return l.parseSlashOrComment
 	case '!':
@@ -196,7 +292,7 @@ func (l *Lexer) parseCode(tCh chan<- *Token) stateFunc { //nolint:gocyclo
 func (l *Lexer) parseInt(tCh chan<- *Token) stateFunc {
 	buf := strings.Builder{}
 
-	defer emitTokenBuffer(tCh, Int, &buf, l.line, l.col)
+	defer l.emitTokenBuffer(tCh, Int, &buf, l.line, l.col)
 
 	for {
 		if l.currEOF {
@@ -226,7 +322,7 @@ func (l *Lexer) parseIdent(tCh chan<- *Token) stateFunc {
 		if !ok {
 			t = Ident
 		}
-		tCh <- newToken(t, literal, line, col)
+		l.emit(tCh, newToken(t, literal, line, col, l.line, l.col))
 	}(l.line, l.col)
 
 	for {
@@ -253,7 +349,7 @@ func (l *Lexer) parseString(tCh chan<- *Token) stateFunc {
 
 	buf := strings.Builder{}
 
-	defer emitTokenBuffer(tCh, String, &buf, l.line, l.col)
+	defer l.emitTokenBuffer(tCh, String, &buf, l.line, l.col)
 
 	if err := l.readNextChar(); err != nil {
 		return l.parseError(err, l.line, l.col)
@@ -403,12 +499,22 @@ func (l *Lexer) parseSlashOrComment(tCh chan<- *Token) stateFunc {
 		return l.parseBlockComment
 	}
 
-	return l.parseToken(Slash, "/")
+	return l.parseOperatorOrAssign(Slash, SlashAssign, "/")
+}
+
+// parseOperatorOrAssign emits a token of type assignType if the operator character op is followed by the
+// equals character '=', otherwise it emits a token of type t.
+func (l *Lexer) parseOperatorOrAssign(t TokenType, assignType TokenType, op string) stateFunc {
+	if l.nextCharIs('=') {
+		return l.parseToken(assignType, op+"=")
+	}
+
+	return l.parseToken(t, op)
 }
 
 func (l *Lexer) parseToken(t TokenType, literal string) stateFunc {
 	return func(tCh chan<- *Token) stateFunc {
-		tCh <- newToken(t, literal, l.line, l.col)
+		l.emit(tCh, newToken(t, literal, l.line, l.col, l.line, l.col+len(literal)))
 
 		for range literal {
 			if err := l.readNextChar(); err != nil {
@@ -423,7 +529,7 @@ func (l *Lexer) parseToken(t TokenType, literal string) stateFunc {
 func (l *Lexer) parseIllegal(tCh chan<- *Token) stateFunc {
 	buf := strings.Builder{}
 
-	defer emitTokenBuffer(tCh, Illegal, &buf, l.line, l.col)
+	defer l.emitTokenBuffer(tCh, Illegal, &buf, l.line, l.col)
 
 	if _, err := buf.WriteRune(l.currChar); err != nil {
 		return l.parseError(err, l.line, l.col)
@@ -434,7 +540,7 @@ func (l *Lexer) parseIllegal(tCh chan<- *Token) stateFunc {
 
 func (l *Lexer) parseError(err error, line int, col int) stateFunc {
 	return func(tCh chan<- *Token) stateFunc {
-		tCh <- newErrorToken(err, line, col)
+		l.emit(tCh, newErrorToken(err, line, col))
 		return nil
 	}
 }
@@ -444,12 +550,21 @@ func (l *Lexer) initialize() error {
 		return err
 	}
 	l.line = 1
-	l.col = 1
-	return l.readNextChar()
+	// reading the first character advances the column to 1
+	l.col = 0
+	if err := l.readNextChar(); err != nil {
+		return err
+	}
+	l.atLineStart = true
+	return nil
 }
 
 func (l *Lexer) skipWhitespace() error {
 	for !l.currEOF && isWhitespaceChar(l.currChar) {
+		if l.inCodeLine && l.currChar == '\n' {
+			break
+		}
+
 		if err := l.readNextChar(); err != nil {
 			return err
 		}
@@ -465,6 +580,10 @@ func (l *Lexer) isAtCodeEnd() bool {
 	return l.currChar == '%' && l.nextCharIs('>')
 }
 
+func (l *Lexer) isAtCodeLineStart() bool {
+	return l.optCodeLines && l.atLineStart && l.currChar == '%' && !l.nextCharIs('>')
+}
+
 func (l *Lexer) isAtBlockCommentEnd() bool {
 	return l.currChar == '*' && l.nextCharIs('/')
 }
@@ -493,8 +612,10 @@ func (l *Lexer) readNextChar() error {
 	case '\n':
 		l.line++
 		l.col = 1
+		l.atLineStart = true
 	default:
 		l.col++
+		l.atLineStart = false
 	}
 
 	r, i, err := l.r.ReadRune()
@@ -517,16 +638,48 @@ func (l *Lexer) nextCharIs(c rune) bool {
 	return !l.nextEOF && (l.nextChar == c)
 }
 
-func emitTokenBuffer(tCh chan<- *Token, t TokenType, buf *strings.Builder, line int, col int) {
-	tCh <- newToken(t, buf.String(), line, col)
+// emit sends t to tCh. If the done channel is closed before t could be sent, t is discarded and token
+// production stops. If the lexer delivers errors separately, errors and illegal tokens are sent to the
+// error channel instead, stopping token production.
+func (l *Lexer) emit(tCh chan<- *Token, t *Token) {
+	if l.done {
+		return
+	}
+
+	if l.errCh != nil {
+		switch {
+		case t.Err != nil:
+			l.errCh <- t.Err
+			l.done = true
+			return
+
+		case t.Type == Illegal:
+			l.errCh <- newParseErrorf(t.Line, t.Col, "illegal token found: %s", t)
+			l.done = true
+			return
+		}
+	}
+
+	select {
+	case tCh <- t:
+	case <-l.doneCh:
+		l.done = true
+	}
+}
+
+// emitTokenBuffer emits a token starting at line and col, and ending at the lexer's current position.
+func (l *Lexer) emitTokenBuffer(tCh chan<- *Token, t TokenType, buf *strings.Builder, line int, col int) {
+	l.emit(tCh, newToken(t, buf.String(), line, col, l.line, l.col))
 }
 
-func newToken(t TokenType, literal string, line int, col int) *Token {
+func newToken(t TokenType, literal string, line int, col int, endLine int, endCol int) *Token {
 	return &Token{
 		Type:    t,
 		Literal: literal,
 		Line:    line,
 		Col:     col,
+		EndLine: endLine,
+		EndCol:  endCol,
 	}
 }
 
@@ -2,8 +2,12 @@ package lexer
 
 import (
 	"bytes"
+	"errors"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type expectedToken struct {
@@ -295,6 +299,37 @@ gh  `},
 				{EOF, ""},
 			},
 		},
+		{
+			`x += 1 -= 2 *= 3 /= 4 + - * /`,
+			[]expectedToken{
+				{Ident, "x"},
+				{PlusAssign, "+="},
+				{Int, "1"},
+				{MinusAssign, "-="},
+				{Int, "2"},
+				{AsteriskAssign, "*="},
+				{Int, "3"},
+				{SlashAssign, "/="},
+				{Int, "4"},
+				{Plus, "+"},
+				{Minus, "-"},
+				{Asterisk, "*"},
+				{Slash, "/"},
+				{EOF, ""},
+			},
+		},
+		{
+			`capture do switch case block extends`,
+			[]expectedToken{
+				{Capture, "capture"},
+				{Do, "do"},
+				{Switch, "switch"},
+				{Case, "case"},
+				{Block, "block"},
+				{Extends, "extends"},
+				{EOF, ""},
+			},
+		},
 		{
 			`// comment %>
 			"foo"
@@ -386,6 +421,331 @@ func TestLexerStartInLiteral(t *testing.T) {
 	}
 }
 
+func TestLexerCodeLines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []expectedToken
+	}{
+		{
+			`% let x = 5`,
+			[]expectedToken{
+				{Literal, ""},
+				{Let, "let"},
+				{Ident, "x"},
+				{Assign, "="},
+				{Int, "5"},
+				{EOF, ""},
+			},
+		},
+		{
+			`foo
+% let x = 5
+bar % baz
+%> qux`,
+			[]expectedToken{
+				{Literal, "foo\n"},
+				{Let, "let"},
+				{Ident, "x"},
+				{Assign, "="},
+				{Int, "5"},
+				{Literal, "bar % baz\n%> qux"},
+				{EOF, ""},
+			},
+		},
+		{
+			`% if x // comment
+foo
+% end
+bar`,
+			[]expectedToken{
+				{Literal, ""},
+				{If, "if"},
+				{Ident, "x"},
+				{Literal, "foo\n"},
+				{End, "end"},
+				{Literal, "bar"},
+				{EOF, ""},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		test := test
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			testTokenString(test.input, test.expected, t, WithCodeLines())
+		})
+	}
+}
+
+func TestLexerPositions(t *testing.T) {
+	l := newLexerString("ab<% x %>\ncd<% y %>", t)
+	tCh, doneCh := l.Tokens()
+
+	defer close(doneCh)
+
+	expected := []struct {
+		literal string
+		line    int
+		col     int
+	}{
+		{"ab", 1, 1},
+		{"x", 1, 6},
+		{"\ncd", 1, 10},
+		{"y", 2, 6},
+	}
+
+	for _, e := range expected {
+		tok := <-tCh
+		if tok.Err != nil {
+			t.Fatalf("error reading next token: %v", tok.Err)
+		}
+
+		if tok.Literal != e.literal || tok.Line != e.line || tok.Col != e.col {
+			t.Fatalf("wrong token, expected=%q@%d:%d, got=%q@%d:%d", e.literal, e.line, e.col, tok.Literal, tok.Line, tok.Col)
+		}
+	}
+}
+
+func TestLexerUnclosedCodeBlock(t *testing.T) {
+	tests := []struct {
+		input string
+		line  int
+		col   int
+	}{
+		{"foo <% bar", 1, 5},
+		{"foo <% bar %> baz <% qux", 1, 19},
+		{"foo\n  <% bar\n", 2, 3},
+		{"<% bar // comment", 1, 1},
+		{`<% "bar`, 1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			l := newLexerString(test.input, t)
+			tCh, doneCh := l.Tokens()
+
+			defer close(doneCh)
+
+			var errTok *Token
+			for tok := range tCh {
+				if tok.Type == Error {
+					errTok = tok
+					break
+				}
+			}
+
+			if errTok == nil {
+				t.Fatalf("expected error token")
+			}
+
+			if !errors.Is(errTok.Err, errUnclosedCodeBlock) {
+				t.Fatalf("wrong error, expected=%v, got=%v", errUnclosedCodeBlock, errTok.Err)
+			}
+
+			if errTok.Line != test.line || errTok.Col != test.col {
+				t.Fatalf("wrong error position, expected=%d:%d, got=%d:%d", test.line, test.col, errTok.Line, errTok.Col)
+			}
+		})
+	}
+}
+
+func TestLexerDone(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	l := newLexerString(strings.Repeat("x <% a + b %> ", 1000), t)
+	tCh, doneCh := l.Tokens()
+
+	<-tCh
+	<-tCh
+
+	close(doneCh)
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("lexer goroutine did not exit after closing done channel")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTokensWithErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedTokens []string
+		expectedError  string
+	}{
+		{"foo <% a + b %>", []string{"foo ", "a", "+", "b", ""}, ""},
+		{"foo <% a ~ b %>", []string{"foo ", "a"}, "parse error at line 1, column 10: illegal token found: '~' (ILLEGAL)"},
+		{"foo <% a", []string{"foo ", "a"}, "parse error at line 1, column 5: unclosed code block"},
+	}
+
+	for i, test := range tests {
+		tCh, errCh, doneCh := newLexerString(test.input, t).TokensWithErrors()
+
+		toks := []string{}
+		for tok := range tCh {
+			if tok.Err != nil || tok.Type == Illegal {
+				t.Fatalf("[%d] unexpected token in token channel: %v", i, tok)
+			}
+			toks = append(toks, tok.Literal)
+		}
+
+		close(doneCh)
+
+		if strings.Join(toks, "|") != strings.Join(test.expectedTokens, "|") {
+			t.Fatalf("[%d] wrong tokens, expected=%v, got=%v", i, test.expectedTokens, toks)
+		}
+
+		err := <-errCh
+
+		if test.expectedError == "" {
+			if err != nil {
+				t.Fatalf("[%d] unexpected error: %v", i, err)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != test.expectedError {
+			t.Fatalf("[%d] wrong error: %v", i, err)
+		}
+
+		if !IsParseError(err) {
+			t.Fatalf("[%d] error is not a parse error: %v", i, err)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     []Opt
+		expected []expectedToken
+	}{
+		{
+			``,
+			nil,
+			[]expectedToken{
+				{EOF, ""},
+			},
+		},
+		{
+			`let x = y`,
+			[]Opt{WithStartInCodeMode()},
+			[]expectedToken{
+				{Let, "let"},
+				{Ident, "x"},
+				{Assign, "="},
+				{Ident, "y"},
+				{EOF, ""},
+			},
+		},
+		{
+			`=+(@),`,
+			[]Opt{WithStartInCodeMode()},
+			[]expectedToken{
+				{Assign, "="},
+				{Plus, "+"},
+				{LeftParen, "("},
+				{Illegal, "@"},
+			},
+		},
+		{
+			`a <% // b %> c <% "d" %> e`,
+			nil,
+			[]expectedToken{
+				{Literal, "a "},
+				{Literal, " c "},
+				{String, "d"},
+				{Literal, " e"},
+				{EOF, ""},
+			},
+		},
+		{
+			`foo
+% let x = 5
+bar`,
+			[]Opt{WithCodeLines()},
+			[]expectedToken{
+				{Literal, "foo\n"},
+				{Let, "let"},
+				{Ident, "x"},
+				{Assign, "="},
+				{Int, "5"},
+				{Literal, "bar"},
+				{EOF, ""},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		test := test
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			toks, err := Tokenize(test.input, test.opts...)
+			if err != nil {
+				t.Fatalf("error tokenizing: %v", err)
+			}
+
+			if len(toks) != len(test.expected) {
+				t.Fatalf("wrong number of tokens, expected=%d, got=%d", len(test.expected), len(toks))
+			}
+
+			for j, tok := range toks {
+				expected := test.expected[j]
+				if tok.Type != expected.typ || tok.Literal != expected.literal {
+					t.Fatalf("wrong token, expected=%s, got=%s", expected.String(), tok.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTokenize_Positions(t *testing.T) {
+	toks, err := Tokenize("foo\n<% bar += \"x\"\n12 %>baz")
+	if err != nil {
+		t.Fatalf("error reading tokens: %v", err)
+	}
+
+	expected := []struct {
+		literal string
+		line    int
+		col     int
+		endLine int
+		endCol  int
+	}{
+		{"foo\n", 1, 1, 2, 1},
+		{"bar", 2, 4, 2, 7},
+		{"+=", 2, 8, 2, 10},
+		{"x", 2, 11, 2, 14},
+		{"12", 3, 1, 3, 3},
+		{"baz", 3, 6, 3, 9},
+		{"", 3, 9, 3, 9},
+	}
+
+	if len(toks) != len(expected) {
+		t.Fatalf("wrong number of tokens, expected=%d, got=%d: %v", len(expected), len(toks), toks)
+	}
+
+	for i, e := range expected {
+		tok := toks[i]
+		if tok.Literal != e.literal || tok.Line != e.line || tok.Col != e.col || tok.EndLine != e.endLine || tok.EndCol != e.endCol {
+			t.Fatalf("wrong token, expected=%s@%d:%d-%d:%d, got=%s@%d:%d-%d:%d",
+				e.literal, e.line, e.col, e.endLine, e.endCol, tok.Literal, tok.Line, tok.Col, tok.EndLine, tok.EndCol)
+		}
+	}
+}
+
+func TestTokenize_Error(t *testing.T) {
+	toks, err := Tokenize("foo <% bar")
+	if !errors.Is(err, errUnclosedCodeBlock) {
+		t.Fatalf("wrong error, expected=%v, got=%v", errUnclosedCodeBlock, err)
+	}
+
+	if len(toks) != 2 || toks[0].Literal != "foo " || toks[1].Literal != "bar" {
+		t.Fatalf("wrong tokens before error: %v", toks)
+	}
+}
+
 func testTokenString(input string, expectedTokens []expectedToken, t *testing.T, opts ...Opt) {
 	t.Helper()
 
@@ -7,7 +7,12 @@ type Token struct {
 	Literal string
 	Line    int
 	Col     int
-	Err     error
+
+	// EndLine and EndCol are the position directly following the token.
+	EndLine int
+	EndCol  int
+
+	Err error
 }
 
 type TokenType int
@@ -46,18 +51,34 @@ const (
 	// Bang is the token type used for the bang character '!'.
 	Bang
 
-	// Plus is the token type used for the plus character '+'.
+	// Plus is the token type used for the plus character '+'. If the character is followed by
+	// the equals character '=', the token type PlusAssign is used for the whole sequence instead.
 	Plus
 
-	// Minus is the token type used for the minus character '-'.
+	// Minus is the token type used for the minus character '-'. If the character is followed by
+	// the equals character '=', the token type MinusAssign is used for the whole sequence instead.
 	Minus
 
-	// Asterisk is the token type used for the asterisk character '*'.
+	// Asterisk is the token type used for the asterisk character '*'. If the character is followed by
+	// the equals character '=', the token type AsteriskAssign is used for the whole sequence instead.
 	Asterisk
 
-	// Slash is the token type used for the slash character '/'.
+	// Slash is the token type used for the slash character '/'. If the character is followed by
+	// the equals character '=', the token type SlashAssign is used for the whole sequence instead.
 	Slash
 
+	// PlusAssign is the token type used for the compound assignment character sequence "+=".
+	PlusAssign
+
+	// MinusAssign is the token type used for the compound assignment character sequence "-=".
+	MinusAssign
+
+	// AsteriskAssign is the token type used for the compound assignment character sequence "*=".
+	AsteriskAssign
+
+	// SlashAssign is the token type used for the compound assignment character sequence "/=".
+	SlashAssign
+
 	// Mod is the token type used for the modulo character '%'.
 	Mod
 
@@ -144,6 +165,21 @@ const (
 	// Capture is the token type used for the capture keyword.
 	Capture
 
+	// Do is the token type used for the do keyword.
+	Do
+
+	// Switch is the token type used for the switch keyword.
+	Switch
+
+	// Case is the token type used for the case keyword.
+	Case
+
+	// Block is the token type used for the block keyword.
+	Block
+
+	// Extends is the token type used for the extends keyword.
+	Extends
+
 	// Literal is the token type used for literal strings in the template, outside of code blocks.
 	Literal
 
@@ -166,6 +202,10 @@ var (
 		Minus:          "MINUS",
 		Asterisk:       "ASTERISK",
 		Slash:          "SLASH",
+		PlusAssign:     "PLUS_ASSIGN",
+		MinusAssign:    "MINUS_ASSIGN",
+		AsteriskAssign: "ASTERISK_ASSIGN",
+		SlashAssign:    "SLASH_ASSIGN",
 		Mod:            "MOD",
 		Equal:          "EQUAL",
 		NotEqual:       "NOT_EQUAL",
@@ -194,6 +234,11 @@ var (
 		Continue:       "CONTINUE",
 		In:             "IN",
 		Capture:        "CAPTURE",
+		Do:             "DO",
+		Switch:         "SWITCH",
+		Case:           "CASE",
+		Block:          "BLOCK",
+		Extends:        "EXTENDS",
 		Literal:        "LITERAL",
 		Error:          "ERROR",
 	}
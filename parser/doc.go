@@ -1,4 +1,7 @@
 // Package parser provides a parser that reads a sequence of lexical tokens from a lexer,
 // transforming them into an abstract syntax tree. This tree can then be evaluated (executed)
 // by an evaluator.
+//
+// The parser can be extended with custom expressions and operators by registering additional
+// parse functions using Parser.RegisterPrefix and Parser.RegisterInfix.
 package parser
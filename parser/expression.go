@@ -2,6 +2,7 @@ package parser
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/lexer"
@@ -22,6 +23,8 @@ func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
 		return nil, err
 	}
 
+	p.setEnd(e)
+
 	for !p.currTokenIs(lexer.EOF) {
 		currPrec, ok := p.currPrecedence()
 		if !ok {
@@ -42,6 +45,8 @@ func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		p.setEnd(e)
 		if !ok {
 			break
 		}
@@ -51,10 +56,15 @@ func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
 }
 
 func (p *Parser) parseLiteralExpression() (ast.Expression, error) {
+	text := p.currToken.Literal
+	if p.trimLiterals {
+		text = strings.TrimSpace(text)
+	}
+
 	e := ast.Literal{
 		StartLine: p.currToken.Line,
 		StartCol:  p.currToken.Col,
-		Text:      p.currToken.Literal,
+		Text:      text,
 	}
 	return &e, p.readNextToken()
 }
@@ -68,7 +78,12 @@ func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 		return nil, err
 	}
 
-	expr, err := p.parseExpression(precedencePrefix)
+	// fold unary minus into int literals so that the minimum int64 value can be parsed
+	if op == "-" && p.currTokenIs(lexer.Int) {
+		return p.parseNegativeIntLiteral(line, col)
+	}
+
+	expr, err := p.parseExpression(PrecedencePrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +122,7 @@ func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
 		return nil, err
 	}
 
-	e, err := p.parseExpression(precedenceLowest)
+	e, err := p.parseExpression(PrecedenceLowest)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +131,14 @@ func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
 		return nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "right paren expected")
 	}
 
-	return e, p.readNextToken()
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	// the grouped expression ends with the right paren
+	p.resetEnd(e)
+
+	return e, nil
 }
 
 func (p *Parser) parseIdentExpression() (ast.Expression, error) {
@@ -146,6 +168,20 @@ func (p *Parser) parseIntLiteral() (ast.Expression, error) {
 	return &e, p.readNextToken()
 }
 
+func (p *Parser) parseNegativeIntLiteral(line int, col int) (ast.Expression, error) {
+	value, err := strconv.ParseInt("-"+p.currToken.Literal, 10, 64)
+	if err != nil {
+		return nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "error parsing int literal: %v", err)
+	}
+
+	e := ast.IntLiteral{
+		StartLine: line,
+		StartCol:  col,
+		Value:     value,
+	}
+	return &e, p.readNextToken()
+}
+
 func (p *Parser) parseStringLiteral() (ast.Expression, error) {
 	e := ast.StringLiteral{
 		StartLine: p.currToken.Line,
@@ -205,7 +241,7 @@ func (p *Parser) parseIfExpression() (ast.Expression, error) {
 		var expr ast.Expression
 		if blockStartTokenType == lexer.If || blockStartTokenType == lexer.ElseIf {
 			var err error
-			expr, err = p.parseExpression(precedenceLowest)
+			expr, err = p.parseExpression(PrecedenceLowest)
 			if err != nil {
 				return nil, err
 			}
@@ -256,6 +292,81 @@ func (p *Parser) parseIfExpression() (ast.Expression, error) {
 	}, nil
 }
 
+func (p *Parser) parseSwitchExpression() (ast.Expression, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	subject, err := p.parseExpression(PrecedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.currTokenIs(lexer.Case) && !p.currTokenIs(lexer.Else) && !p.currTokenIs(lexer.End) {
+		return nil, p.newUnexpectedTokenError(lexer.Case, lexer.Else, lexer.End)
+	}
+
+	cases := []ast.CaseBlock{}
+
+	blockStartToken := p.currToken
+	haveElse := false
+
+	if err = p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	for blockStartToken.Type != lexer.End {
+		if haveElse {
+			return nil, newParseErrorf(blockStartToken.Line, blockStartToken.Col, "else block must be last in switch expression")
+		}
+
+		c := ast.CaseBlock{
+			StartLine: blockStartToken.Line,
+			StartCol:  blockStartToken.Col,
+		}
+
+		if blockStartToken.Type == lexer.Case {
+			c.Operator = "=="
+			if _, ok := caseOperators[p.currToken.Type]; ok {
+				c.Operator = p.currToken.Literal
+				if err = p.readNextToken(); err != nil {
+					return nil, err
+				}
+			}
+
+			if c.Value, err = p.parseExpression(PrecedenceLowest); err != nil {
+				return nil, err
+			}
+		} else {
+			haveElse = true
+		}
+
+		b, endToken, err := p.parseBlock([]lexer.TokenType{
+			lexer.Case,
+			lexer.Else,
+			lexer.End,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		c.Block = *b
+		cases = append(cases, c)
+
+		blockStartToken = endToken
+	}
+
+	return &ast.SwitchExpression{
+		StartLine: line,
+		StartCol:  col,
+		Subject:   subject,
+		Cases:     cases,
+	}, nil
+}
+
 func (p *Parser) parseForExpression() (ast.Expression, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
@@ -269,16 +380,46 @@ func (p *Parser) parseForExpression() (ast.Expression, error) {
 		return nil, err
 	}
 
+	var valueIdent *ast.Ident
+	if p.currTokenIs(lexer.Colon) {
+		if err = p.readNextToken(); err != nil {
+			return nil, err
+		}
+
+		valueLine := p.currToken.Line
+		valueCol := p.currToken.Col
+
+		valueIdent, err = p.parseIdentExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if valueIdent.Name == ident.Name {
+			return nil, newParseErrorf(valueLine, valueCol, "key and value identifier must differ: %s", ident.Name)
+		}
+	}
+
 	var statusIdent *ast.Ident
 	if p.currTokenIs(lexer.Comma) {
 		if err = p.readNextToken(); err != nil {
 			return nil, err
 		}
 
+		statusLine := p.currToken.Line
+		statusCol := p.currToken.Col
+
 		statusIdent, err = p.parseIdentExpr()
 		if err != nil {
 			return nil, err
 		}
+
+		if statusIdent.Name == ident.Name {
+			return nil, newParseErrorf(statusLine, statusCol, "loop and status identifier must differ: %s", ident.Name)
+		}
+
+		if valueIdent != nil && statusIdent.Name == valueIdent.Name {
+			return nil, newParseErrorf(statusLine, statusCol, "value and status identifier must differ: %s", valueIdent.Name)
+		}
 	}
 
 	if !p.currTokenIs(lexer.In) {
@@ -289,50 +430,47 @@ func (p *Parser) parseForExpression() (ast.Expression, error) {
 		return nil, err
 	}
 
-	expr, err := p.parseExpression(precedenceLowest)
+	expr, err := p.parseExpression(PrecedenceLowest)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: replace all this by call to parseBlock
-
-	blockLine := p.currToken.Line
-	blockCol := p.currToken.Col
-
-	stmts := []ast.Statement{}
+	// allow break and continue statements inside the loop's block, but not in the else block
+	p.loopLevel++
+	block, endToken, err := p.parseBlock([]lexer.TokenType{
+		lexer.Else,
+		lexer.End,
+	})
+	p.loopLevel--
 
-	for !p.currTokenIs(lexer.EOF) {
-		if p.currTokenIs(lexer.End) {
-			break
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		st, err := p.parseStatement()
+	var elseBlock *ast.Block
+	if endToken.Type == lexer.Else {
+		elseBlock, endToken, err = p.parseBlock([]lexer.TokenType{
+			lexer.Else,
+			lexer.End,
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		stmts = append(stmts, st)
-	}
-
-	if !p.currTokenIs(lexer.End) {
-		return nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "end of for expression not found")
-	}
-
-	if err = p.readNextToken(); err != nil {
-		return nil, err
+		if endToken.Type == lexer.Else {
+			return nil, newParseErrorf(endToken.Line, endToken.Col, "for expression can only have a single else block")
+		}
 	}
 
 	return &ast.ForExpression{
 		StartLine:   line,
 		StartCol:    col,
 		Ident:       *ident,
+		ValueIdent:  valueIdent,
 		StatusIdent: statusIdent,
 		RangeExpr:   expr,
-		Block: ast.Block{
-			StartLine:  blockLine,
-			StartCol:   blockCol,
-			Statements: stmts,
-		},
+		Block:       *block,
+		ElseBlock:   elseBlock,
 	}, nil
 }
 
@@ -340,6 +478,11 @@ func (p *Parser) parseBlock(endTokenTypes []lexer.TokenType) (*ast.Block, *lexer
 	line := p.currToken.Line
 	col := p.currToken.Col
 
+	// whitespace statements only apply until the end of the block
+	defer func(trim bool) {
+		p.trimLiterals = trim
+	}(p.trimLiterals)
+
 	statements := []ast.Statement{}
 
 	for !p.currTokenIs(lexer.EOF) && !p.currTokenIsOneOf(endTokenTypes) {
@@ -354,7 +497,7 @@ func (p *Parser) parseBlock(endTokenTypes []lexer.TokenType) (*ast.Block, *lexer
 	endToken := p.currToken
 
 	if p.currTokenIs(lexer.EOF) {
-		return nil, nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "end of block not found")
+		return nil, nil, p.newUnexpectedTokenError(endTokenTypes...)
 	}
 
 	if err := p.readNextToken(); err != nil {
@@ -376,11 +519,12 @@ func (p *Parser) parseCallExpression(left ast.Expression, currPrecedence int) (a
 	params := []ast.Expression{}
 
 	for !p.currTokenIs(lexer.EOF) {
+		// no params, or trailing comma
 		if p.currTokenIs(lexer.RightParen) {
 			break
 		}
 
-		param, err := p.parseExpression(precedenceLowest)
+		param, err := p.parseExpression(PrecedenceLowest)
 		if err != nil {
 			return nil, false, err
 		}
@@ -392,7 +536,7 @@ func (p *Parser) parseCallExpression(left ast.Expression, currPrecedence int) (a
 		}
 
 		if !p.currTokenIs(lexer.Comma) {
-			return nil, false, newParseErrorf(p.currToken.Line, p.currToken.Col, "comma expected")
+			return nil, false, p.newUnexpectedTokenError(lexer.Comma, lexer.RightParen)
 		}
 
 		if err = p.readNextToken(); err != nil {
@@ -401,7 +545,7 @@ func (p *Parser) parseCallExpression(left ast.Expression, currPrecedence int) (a
 	}
 
 	if !p.currTokenIs(lexer.RightParen) {
-		return nil, false, newParseErrorf(p.currToken.Line, p.currToken.Col, "right paren expected")
+		return nil, false, p.newUnexpectedTokenError(lexer.RightParen)
 	}
 
 	if err := p.readNextToken(); err != nil {
@@ -441,7 +585,7 @@ func (p *Parser) parseFieldExpression(left ast.Expression, currPrecedence int) (
 		}, true, p.readNextToken()
 	}
 
-	expr, err := p.parseExpression(precedenceLowest)
+	expr, err := p.parseExpression(PrecedenceLowest)
 	if err != nil {
 		return nil, false, err
 	}
@@ -478,6 +622,56 @@ func (p *Parser) parseCaptureExpression() (ast.Expression, error) {
 	}, nil
 }
 
+func (p *Parser) parseDoExpression() (ast.Expression, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	b, _, err := p.parseBlock([]lexer.TokenType{lexer.End})
+	if err != nil {
+		return nil, err
+	}
+	return &ast.DoExpression{
+		StartLine: line,
+		StartCol:  col,
+		Block:     *b,
+	}, nil
+}
+
+func (p *Parser) parseBlockExpression() (ast.Expression, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	if err := p.expectNext(lexer.String); err != nil {
+		return nil, err
+	}
+
+	name := p.currToken.Literal
+	if _, ok := p.blockNames[name]; ok {
+		return nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "duplicate block name: %s", name)
+	}
+
+	p.blockNames[name] = struct{}{}
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	b, _, err := p.parseBlock([]lexer.TokenType{lexer.End})
+	if err != nil {
+		return nil, err
+	}
+	return &ast.BlockExpression{
+		StartLine: line,
+		StartCol:  col,
+		Name:      name,
+		Block:     *b,
+	}, nil
+}
+
 func (p *Parser) parseHashExpression() (ast.Expression, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
@@ -496,24 +690,36 @@ func (p *Parser) parseHashExpression() (ast.Expression, error) {
 
 		if !first {
 			if !p.currTokenIs(lexer.Comma) {
-				return nil, newParseErrorf(p.currToken.Line, p.currToken.Col, "expected comma before next hash element")
+				return nil, p.newUnexpectedTokenError(lexer.Comma, lexer.RightBrace)
 			}
 
 			if err := p.readNextToken(); err != nil {
 				return nil, err
 			}
+
+			// allow trailing comma
+			if p.currTokenIs(lexer.RightBrace) {
+				break
+			}
 		}
 
 		keyLine := p.currToken.Line
 		keyCol := p.currToken.Col
 
-		keyExpr, err := p.parseExpression(precedenceLowest)
+		keyExpr, err := p.parseExpression(PrecedenceLowest)
 		if err != nil {
 			return nil, err
 		}
 
-		if _, ok := keyExpr.(*ast.StringLiteral); !ok {
-			return nil, newParseErrorf(keyLine, keyCol, "key in hash expression is not a string: %T", keyExpr)
+		// identifiers are used as keys by their name
+		var key string
+		switch k := keyExpr.(type) {
+		case *ast.StringLiteral:
+			key = k.Value
+		case *ast.Ident:
+			key = k.Name
+		default:
+			return nil, newParseErrorf(keyLine, keyCol, "key in hash expression is not a string or identifier: %T", keyExpr)
 		}
 
 		if !p.currTokenIs(lexer.Colon) {
@@ -524,7 +730,6 @@ func (p *Parser) parseHashExpression() (ast.Expression, error) {
 			return nil, err
 		}
 
-		key := keyExpr.(*ast.StringLiteral).Value
 		if key == "" {
 			return nil, newParseErrorf(keyLine, keyCol, "empty key in hash expression")
 		}
@@ -533,7 +738,7 @@ func (p *Parser) parseHashExpression() (ast.Expression, error) {
 			return nil, newParseErrorf(keyLine, keyCol, "duplicate key in hash expression: %s", key)
 		}
 
-		value, err := p.parseExpression(precedenceLowest)
+		value, err := p.parseExpression(PrecedenceLowest)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/lexer"
 )
@@ -10,26 +12,44 @@ import (
 type Parser struct {
 	ch               <-chan *lexer.Token
 	doneCh           chan<- struct{}
+	prevToken        *lexer.Token
 	currToken        *lexer.Token
 	nextToken        *lexer.Token
 	prefixParseFuncs map[lexer.TokenType]prefixParseFunc
 	infixParseFuncs  map[lexer.TokenType]infixParseFunc
+	precedences      map[lexer.TokenType]int
+	trimLiterals     bool
+	blockNames       map[string]struct{}
+	haveExtends      bool
+	loopLevel        int
 }
 
 type prefixParseFunc func() (ast.Expression, error)
 
 type infixParseFunc func(left ast.Expression, currPrecedence int) (ast.Expression, bool, error)
 
+// PrefixParseFunc is a function that parses an expression starting at the current token, such as a
+// literal or a prefix operator expression. When it returns, the current token must be the first token
+// following the expression.
+type PrefixParseFunc func() (ast.Expression, error)
+
+// InfixParseFunc is a function that parses an expression starting at the current token, such as an
+// infix operator expression, with left being the expression that has already been parsed to the
+// left of the current token. When it returns, the current token must be the first token following
+// the expression.
+type InfixParseFunc func(left ast.Expression) (ast.Expression, error)
+
+// Operator precedences, from lowest to highest, to be used with RegisterInfix and ParseExpression.
 const (
-	precedenceLowest = iota + 1
-	precedenceOr
-	precedenceAnd
-	precedenceEquality
-	precedenceRelational
-	precedenceAdditive
-	precedenceMultiplicative
-	precedencePrefix
-	precedenceField
+	PrecedenceLowest = iota + 1
+	PrecedenceOr
+	PrecedenceAnd
+	PrecedenceEquality
+	PrecedenceRelational
+	PrecedenceAdditive
+	PrecedenceMultiplicative
+	PrecedencePrefix
+	PrecedenceField
 )
 
 var (
@@ -37,33 +57,90 @@ var (
 		Type: -1,
 	}
 
+	caseOperators = map[lexer.TokenType]struct{}{
+		lexer.Equal:          {},
+		lexer.NotEqual:       {},
+		lexer.LessThan:       {},
+		lexer.LessOrEqual:    {},
+		lexer.GreaterThan:    {},
+		lexer.GreaterOrEqual: {},
+	}
+
+	// compoundAssignOperators maps compound assignment token types to the operators of the corresponding
+	// infix expressions.
+	compoundAssignOperators = map[lexer.TokenType]string{
+		lexer.PlusAssign:     "+",
+		lexer.MinusAssign:    "-",
+		lexer.AsteriskAssign: "*",
+		lexer.SlashAssign:    "/",
+	}
+
 	precedences = map[lexer.TokenType]int{
-		lexer.Or:             precedenceOr,
-		lexer.And:            precedenceAnd,
-		lexer.Equal:          precedenceEquality,
-		lexer.NotEqual:       precedenceEquality,
-		lexer.LessThan:       precedenceRelational,
-		lexer.LessOrEqual:    precedenceRelational,
-		lexer.GreaterThan:    precedenceRelational,
-		lexer.GreaterOrEqual: precedenceRelational,
-		lexer.Plus:           precedenceAdditive,
-		lexer.Minus:          precedenceAdditive,
-		lexer.Slash:          precedenceMultiplicative,
-		lexer.Asterisk:       precedenceMultiplicative,
-		lexer.Mod:            precedenceMultiplicative,
-		lexer.LeftParen:      precedenceField,
-		lexer.Dot:            precedenceField,
-		lexer.LeftBracket:    precedenceField,
+		lexer.Or:             PrecedenceOr,
+		lexer.And:            PrecedenceAnd,
+		lexer.Equal:          PrecedenceEquality,
+		lexer.NotEqual:       PrecedenceEquality,
+		lexer.LessThan:       PrecedenceRelational,
+		lexer.LessOrEqual:    PrecedenceRelational,
+		lexer.GreaterThan:    PrecedenceRelational,
+		lexer.GreaterOrEqual: PrecedenceRelational,
+		lexer.Plus:           PrecedenceAdditive,
+		lexer.Minus:          PrecedenceAdditive,
+		lexer.Slash:          PrecedenceMultiplicative,
+		lexer.Asterisk:       PrecedenceMultiplicative,
+		lexer.Mod:            PrecedenceMultiplicative,
+		lexer.LeftParen:      PrecedenceField,
+		lexer.Dot:            PrecedenceField,
+		lexer.LeftBracket:    PrecedenceField,
 	}
 )
 
 // New returns a new parser that reads a sequence of tokens from tCh. When the parser is done parsing,
 // or when an error occurred, it closes doneCh.
 func New(tCh <-chan *lexer.Token, doneCh chan<- struct{}) *Parser {
-	return &Parser{
+	p := &Parser{
 		ch:     tCh,
 		doneCh: doneCh,
 	}
+
+	p.registerParseFuncs()
+
+	return p
+}
+
+// RegisterPrefix registers f to parse expressions starting with tokens of type t, replacing any
+// existing parse function for t. It must be called before Parse.
+func (p *Parser) RegisterPrefix(t lexer.TokenType, f PrefixParseFunc) {
+	p.registerPrefixParseFunc(t, prefixParseFunc(f))
+}
+
+// RegisterInfix registers f to parse infix expressions with operator tokens of type t, with the
+// operator binding with the given precedence. Any existing parse function for t is replaced.
+// It must be called before Parse.
+func (p *Parser) RegisterInfix(t lexer.TokenType, f InfixParseFunc, precedence int) {
+	p.registerInfixParseFunc(t, func(left ast.Expression, currPrecedence int) (ast.Expression, bool, error) {
+		e, err := f(left)
+		return e, true, err
+	})
+
+	p.precedences[t] = precedence
+}
+
+// CurrToken returns the current token. It is intended to be used by custom parse functions.
+func (p *Parser) CurrToken() *lexer.Token {
+	return p.currToken
+}
+
+// ReadNextToken advances to the next token. It is intended to be used by custom parse functions.
+func (p *Parser) ReadNextToken() error {
+	return p.readNextToken()
+}
+
+// ParseExpression parses an expression starting at the current token, stopping at operators that
+// bind with a precedence lower than or equal to precedence. It is intended to be used by custom
+// parse functions.
+func (p *Parser) ParseExpression(precedence int) (ast.Expression, error) {
+	return p.parseExpression(precedence)
 }
 
 // Parse reads the sequence of tokens and transforms it into an abstract syntax tree, a program.
@@ -96,19 +173,23 @@ func (p *Parser) Parse() (*ast.Program, error) {
 	}, nil
 }
 
-func (p *Parser) initialize() error {
+func (p *Parser) registerParseFuncs() {
 	p.prefixParseFuncs = map[lexer.TokenType]prefixParseFunc{}
 	p.registerPrefixParseFunc(lexer.Ident, p.parseIdentExpression)
 	p.registerPrefixParseFunc(lexer.Int, p.parseIntLiteral)
 	p.registerPrefixParseFunc(lexer.String, p.parseStringLiteral)
 	p.registerPrefixParseFunc(lexer.Bang, p.parsePrefixExpression)
 	p.registerPrefixParseFunc(lexer.Minus, p.parsePrefixExpression)
+	p.registerPrefixParseFunc(lexer.Plus, p.parsePrefixExpression)
 	p.registerPrefixParseFunc(lexer.True, p.parseBoolLiteral)
 	p.registerPrefixParseFunc(lexer.False, p.parseBoolLiteral)
 	p.registerPrefixParseFunc(lexer.LeftParen, p.parseGroupedExpression)
 	p.registerPrefixParseFunc(lexer.If, p.parseIfExpression)
+	p.registerPrefixParseFunc(lexer.Switch, p.parseSwitchExpression)
 	p.registerPrefixParseFunc(lexer.Nil, p.parseNilLiteral)
 	p.registerPrefixParseFunc(lexer.Capture, p.parseCaptureExpression)
+	p.registerPrefixParseFunc(lexer.Do, p.parseDoExpression)
+	p.registerPrefixParseFunc(lexer.Block, p.parseBlockExpression)
 	p.registerPrefixParseFunc(lexer.For, p.parseForExpression)
 	p.registerPrefixParseFunc(lexer.LeftBrace, p.parseHashExpression)
 	p.registerPrefixParseFunc(lexer.Literal, p.parseLiteralExpression)
@@ -131,7 +212,17 @@ func (p *Parser) initialize() error {
 	p.registerInfixParseFunc(lexer.Dot, p.parseFieldExpression)
 	p.registerInfixParseFunc(lexer.LeftBracket, p.parseFieldExpression)
 
+	p.precedences = make(map[lexer.TokenType]int, len(precedences))
+	for t, pr := range precedences {
+		p.precedences[t] = pr
+	}
+}
+
+func (p *Parser) initialize() error {
+	p.blockNames = map[string]struct{}{}
+
 	// prevent nil pointers
+	p.prevToken = &startToken
 	p.currToken = &startToken
 	p.nextToken = &startToken
 
@@ -153,6 +244,21 @@ func (p *Parser) expectNext(t lexer.TokenType) error {
 	return p.readNextToken()
 }
 
+// newUnexpectedTokenError returns an error stating that a token of one of the types t was expected,
+// but the current token was found instead.
+func (p *Parser) newUnexpectedTokenError(t ...lexer.TokenType) error {
+	if len(t) == 1 {
+		return newParseErrorf(p.currToken.Line, p.currToken.Col, "expected token %s, got %s instead", t[0], p.currToken)
+	}
+
+	names := make([]string, len(t))
+	for i, tt := range t {
+		names[i] = tt.String()
+	}
+
+	return newParseErrorf(p.currToken.Line, p.currToken.Col, "expected one of %s, got %s instead", strings.Join(names, ", "), p.currToken)
+}
+
 func (p *Parser) currTokenIs(t lexer.TokenType) bool {
 	return p.currToken.Type == t
 }
@@ -175,6 +281,7 @@ func (p *Parser) readNextToken() error {
 		return nil
 	}
 
+	p.prevToken = p.currToken
 	p.currToken = p.nextToken
 
 	if p.currTokenIs(lexer.EOF) {
@@ -202,7 +309,31 @@ func (p *Parser) registerInfixParseFunc(t lexer.TokenType, f infixParseFunc) {
 	p.infixParseFuncs[t] = f
 }
 
+// setEnd sets the end position of e to the end of the previous token, unless the end position is
+// already known.
+func (p *Parser) setEnd(e ast.Expression) {
+	if s, ok := e.(ast.Spanned); ok {
+		if line, _ := s.End(); line != 0 {
+			return
+		}
+	}
+
+	p.resetEnd(e)
+}
+
+// resetEnd sets the end position of e to the end of the previous token.
+func (p *Parser) resetEnd(e ast.Expression) {
+	s, ok := e.(interface {
+		SetEnd(line int, col int)
+	})
+	if !ok {
+		return
+	}
+
+	s.SetEnd(p.prevToken.EndLine, p.prevToken.EndCol)
+}
+
 func (p *Parser) currPrecedence() (int, bool) {
-	pr, ok := precedences[p.currToken.Type]
+	pr, ok := p.precedences[p.currToken.Type]
 	return pr, ok
 }
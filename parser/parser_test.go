@@ -3,6 +3,7 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"testing"
@@ -21,21 +22,21 @@ func TestParserStartInLiteral(t *testing.T) {
 			&ast.Program{
 				Statements: []ast.Statement{
 					&ast.ExpressionStatement{
-						Expression: newLiteral("foo "),
+						Expression: ast.NewLiteral("foo "),
 					},
 					&ast.ExpressionStatement{
 						Expression: &ast.InfixExpression{
-							Left:     newIntLiteral(5),
+							Left:     ast.NewIntLiteral(5),
 							Operator: "+",
 							Right: &ast.InfixExpression{
-								Left:     newIntLiteral(6),
+								Left:     ast.NewIntLiteral(6),
 								Operator: "*",
-								Right:    newIntLiteral(7),
+								Right:    ast.NewIntLiteral(7),
 							},
 						},
 					},
 					&ast.ExpressionStatement{
-						Expression: newLiteral(" bar"),
+						Expression: ast.NewLiteral(" bar"),
 					},
 				},
 			},
@@ -49,6 +50,458 @@ func TestParserStartInLiteral(t *testing.T) {
 	}
 }
 
+func TestParserWhitespace(t *testing.T) {
+	testParser("a\n <% whitespace trim %>\n b \n<% whitespace keep %>\n c \n<% whitespace %>", &ast.Program{
+		Statements: []ast.Statement{
+			ast.NewExpressionStatement(ast.NewLiteral("a\n ")),
+			&ast.WhitespaceStatement{Trim: true},
+			ast.NewExpressionStatement(ast.NewLiteral("b")),
+			&ast.WhitespaceStatement{Trim: false},
+			ast.NewExpressionStatement(ast.NewLiteral("\n c \n")),
+			// not followed by trim or keep, so it is a regular identifier
+			ast.NewExpressionStatement(ast.NewIdent("whitespace")),
+		},
+	}, t)
+}
+
+func TestParseSwitch(t *testing.T) {
+	testParser(`switch x case < 10 "small" case 100 "hundred" case >= 100 "big" else "medium" end`, &ast.Program{
+		Statements: []ast.Statement{
+			ast.NewExpressionStatement(&ast.SwitchExpression{
+				Subject: ast.NewIdent("x"),
+				Cases: []ast.CaseBlock{
+					{
+						Operator: "<",
+						Value:    ast.NewIntLiteral(10),
+						Block: ast.Block{
+							Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewStringLiteral("small"))},
+						},
+					},
+					{
+						Operator: "==",
+						Value:    ast.NewIntLiteral(100),
+						Block: ast.Block{
+							Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewStringLiteral("hundred"))},
+						},
+					},
+					{
+						Operator: ">=",
+						Value:    ast.NewIntLiteral(100),
+						Block: ast.Block{
+							Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewStringLiteral("big"))},
+						},
+					},
+					{
+						Block: ast.Block{
+							Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewStringLiteral("medium"))},
+						},
+					},
+				},
+			}),
+		},
+	}, t, lexer.WithStartInCodeMode())
+}
+
+func TestParseSwitch_ElseNotLast(t *testing.T) {
+	l := newLexerString(`switch x else 1 case 2 3 end`, t, lexer.WithStartInCodeMode())
+	tCh, doneCh := l.Tokens()
+
+	_, err := New(tCh, doneCh).Parse()
+	if err == nil || err.Error() != "parse error at line 1, column 17: else block must be last in switch expression" {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestParseBlock(t *testing.T) {
+	testParser(`extends "layout" block "title" "foo" end block "content" block "inner" end end`, &ast.Program{
+		Statements: []ast.Statement{
+			&ast.ExtendsStatement{
+				Name: "layout",
+			},
+			ast.NewExpressionStatement(&ast.BlockExpression{
+				Name: "title",
+				Block: ast.Block{
+					Statements: []ast.Statement{ast.NewExpressionStatement(ast.NewStringLiteral("foo"))},
+				},
+			}),
+			ast.NewExpressionStatement(&ast.BlockExpression{
+				Name: "content",
+				Block: ast.Block{
+					Statements: []ast.Statement{
+						ast.NewExpressionStatement(&ast.BlockExpression{
+							Name: "inner",
+						}),
+					},
+				},
+			}),
+		},
+	}, t, lexer.WithStartInCodeMode())
+}
+
+func TestParseBlock_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`block "a" end block "b" block "a" end end`, "parse error at line 1, column 31: duplicate block name: a"},
+		{`extends "a" extends "b"`, "parse error at line 1, column 13: duplicate extends statement"},
+		{`block x end`, "parse error at line 1, column 7: expected token STRING, got 'x' (IDENT) instead"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseAssign(t *testing.T) {
+	testParser(`let a.b = 1 let a["c"].d = 2`, &ast.Program{
+		Statements: []ast.Statement{
+			&ast.AssignStatement{
+				Target:     ast.NewField(ast.NewIdent("a"), ast.NewStringLiteral("b")),
+				Expression: ast.NewIntLiteral(1),
+			},
+			&ast.AssignStatement{
+				Target:     ast.NewField(ast.NewField(ast.NewIdent("a"), ast.NewStringLiteral("c")), ast.NewStringLiteral("d")),
+				Expression: ast.NewIntLiteral(2),
+			},
+		},
+	}, t, lexer.WithStartInCodeMode())
+}
+
+func TestParseAssign_WithoutLet(t *testing.T) {
+	testParser(`a.b = 1 a["c"].d = 2 + 3 a`, &ast.Program{
+		Statements: []ast.Statement{
+			&ast.AssignStatement{
+				Target:     ast.NewField(ast.NewIdent("a"), ast.NewStringLiteral("b")),
+				Expression: ast.NewIntLiteral(1),
+			},
+			&ast.AssignStatement{
+				Target:     ast.NewField(ast.NewField(ast.NewIdent("a"), ast.NewStringLiteral("c")), ast.NewStringLiteral("d")),
+				Expression: ast.NewInfix(ast.NewIntLiteral(2), "+", ast.NewIntLiteral(3)),
+			},
+			ast.NewExpressionStatement(ast.NewIdent("a")),
+		},
+	}, t, lexer.WithStartInCodeMode())
+}
+
+func TestParseCompoundAssign(t *testing.T) {
+	testParser(`x += 1 x -= 2 x *= 3 + 4 x /= 5`, &ast.Program{
+		Statements: []ast.Statement{
+			ast.NewLet("x", ast.NewInfix(ast.NewIdent("x"), "+", ast.NewIntLiteral(1))),
+			ast.NewLet("x", ast.NewInfix(ast.NewIdent("x"), "-", ast.NewIntLiteral(2))),
+			ast.NewLet("x", ast.NewInfix(ast.NewIdent("x"), "*", ast.NewInfix(ast.NewIntLiteral(3), "+", ast.NewIntLiteral(4)))),
+			ast.NewLet("x", ast.NewInfix(ast.NewIdent("x"), "/", ast.NewIntLiteral(5))),
+		},
+	}, t, lexer.WithStartInCodeMode())
+}
+
+func TestParseAssign_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a.b() = 1`, "parse error at line 1, column 5: field or index expression expected as assignment target"},
+		{`let a.b 1`, "parse error at line 1, column 9: expected token ASSIGN, got '1' (INT) instead"},
+		{`a = 1`, "parse error at line 1, column 1: field or index expression expected as assignment target"},
+		{`a.b() = 1`, "parse error at line 1, column 1: field or index expression expected as assignment target"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseFor_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for i in x "a" else "b" else "c" end`, "parse error at line 1, column 25: for expression can only have a single else block"},
+		{`for i in x "a" else "b"`, "parse error at line 1, column 24: expected one of ELSE, END, got '' (EOF) instead"},
+		{`for k: k in x end`, "parse error at line 1, column 8: key and value identifier must differ: k"},
+		{`for k: v, v in x end`, "parse error at line 1, column 11: value and status identifier must differ: v"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseHash_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{ x: 1, "x": 2 }`, "parse error at line 1, column 9: duplicate key in hash expression: x"},
+		{`{ "x": 1, x: 2 }`, "parse error at line 1, column 11: duplicate key in hash expression: x"},
+		{`{ x.y: 1 }`, "parse error at line 1, column 3: key in hash expression is not a string or identifier: *ast.FieldExpression"},
+		{`{ 1: 1 }`, "parse error at line 1, column 3: key in hash expression is not a string or identifier: *ast.IntLiteral"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseTrailingComma_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x(,)`, "parse error at line 1, column 3: no prefix parse function found for ',' (COMMA)"},
+		{`x(a,,)`, "parse error at line 1, column 5: no prefix parse function found for ',' (COMMA)"},
+		{`{,}`, "parse error at line 1, column 2: no prefix parse function found for ',' (COMMA)"},
+		{`{ "x": 1,, }`, "parse error at line 1, column 10: no prefix parse function found for ',' (COMMA)"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseExpectedTokens_Error(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`foo(1 2)`, "parse error at line 1, column 7: expected one of COMMA, RIGHT_PAREN, got '2' (INT) instead"},
+		{`foo(1, bar baz)`, "parse error at line 1, column 12: expected one of COMMA, RIGHT_PAREN, got 'baz' (IDENT) instead"},
+		{`foo(1`, "parse error at line 1, column 6: expected one of COMMA, RIGHT_PAREN, got '' (EOF) instead"},
+		{`{ "x": 1 "y": 2 }`, "parse error at line 1, column 10: expected one of COMMA, RIGHT_BRACE, got 'y' (STRING) instead"},
+		{`switch x 1 end`, "parse error at line 1, column 10: expected one of CASE, ELSE, END, got '1' (INT) instead"},
+		{`if x 1`, "parse error at line 1, column 7: expected one of ELSE_IF, ELSE, END, got '' (EOF) instead"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParseBreakContinue_OutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`break`, "parse error at line 1, column 1: break outside of loop"},
+		{`if x continue end`, "parse error at line 1, column 6: continue outside of loop"},
+		{`for i in x end break`, "parse error at line 1, column 16: break outside of loop"},
+		{`for i in x else break end`, "parse error at line 1, column 17: break outside of loop"},
+	}
+
+	for _, test := range tests {
+		l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+		tCh, doneCh := l.Tokens()
+
+		_, err := New(tCh, doneCh).Parse()
+		if err == nil || err.Error() != test.expected {
+			t.Fatalf("wrong error: %v", err)
+		}
+	}
+}
+
+func TestParser_RegisterPrefix(t *testing.T) {
+	l := newLexerString(`*x + 1`, t, lexer.WithStartInCodeMode())
+	tCh, doneCh := l.Tokens()
+
+	p := New(tCh, doneCh)
+
+	p.RegisterPrefix(lexer.Asterisk, func() (ast.Expression, error) {
+		tok := p.CurrToken()
+
+		if err := p.ReadNextToken(); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.ParseExpression(PrecedencePrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.PrefixExpression{
+			StartLine:  tok.Line,
+			StartCol:   tok.Col,
+			Operator:   tok.Literal,
+			Expression: expr,
+		}, nil
+	})
+
+	prog, err := p.Parse()
+	if err != nil {
+		t.Fatalf("error parsing program: %v", err)
+	}
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("program does not have expected number of statements, expected=1, got=%d", len(prog.Statements))
+	}
+
+	testStatement(prog.Statements[0], &ast.ExpressionStatement{
+		Expression: &ast.InfixExpression{
+			Left: &ast.PrefixExpression{
+				Operator:   "*",
+				Expression: ast.NewIdent("x"),
+			},
+			Operator: "+",
+			Right:    ast.NewIntLiteral(1),
+		},
+	}, t)
+}
+
+func TestParser_RegisterInfix(t *testing.T) {
+	l := newLexerString(`a % b * c`, t, lexer.WithStartInCodeMode())
+	tCh, doneCh := l.Tokens()
+
+	p := New(tCh, doneCh)
+
+	p.RegisterInfix(lexer.Mod, func(left ast.Expression) (ast.Expression, error) {
+		op := p.CurrToken().Literal
+
+		if err := p.ReadNextToken(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.ParseExpression(PrecedenceAdditive)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.InfixExpression{
+			Left:     left,
+			Operator: op,
+			Right:    right,
+		}, nil
+	}, PrecedenceAdditive)
+
+	prog, err := p.Parse()
+	if err != nil {
+		t.Fatalf("error parsing program: %v", err)
+	}
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("program does not have expected number of statements, expected=1, got=%d", len(prog.Statements))
+	}
+
+	testStatement(prog.Statements[0], &ast.ExpressionStatement{
+		Expression: &ast.InfixExpression{
+			Left:     ast.NewIdent("a"),
+			Operator: "%",
+			Right: &ast.InfixExpression{
+				Left:     ast.NewIdent("b"),
+				Operator: "*",
+				Right:    ast.NewIdent("c"),
+			},
+		},
+	}, t)
+}
+
+func TestParse_Spans(t *testing.T) {
+	tests := []struct {
+		input   string
+		endLine int
+		endCol  int
+	}{
+		{`a + b * c`, 1, 10},
+		{`(a + b) * c`, 1, 12},
+		{`(a + b)`, 1, 8},
+		{`((a))`, 1, 6},
+		{`c * (a + b)`, 1, 12},
+		{`foo(1, bar)`, 1, 12},
+		{"foo(\n  1,\n)", 3, 2},
+		{`foo.bar(x)[0]`, 1, 14},
+		{`-x`, 1, 3},
+		{`"foo"`, 1, 6},
+		{`if x 1 else 2 end`, 1, 18},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			l := newLexerString(test.input, t, lexer.WithStartInCodeMode())
+			prog := parse(l, t)
+
+			s, ok := prog.Statements[0].(*ast.ExpressionStatement).Expression.(ast.Spanned)
+			if !ok {
+				t.Fatalf("expression does not implement ast.Spanned: %T", prog.Statements[0].(*ast.ExpressionStatement).Expression)
+			}
+
+			line, col := s.End()
+			if line != test.endLine || col != test.endCol {
+				t.Fatalf("wrong end position, expected=%d:%d, got=%d:%d", test.endLine, test.endCol, line, col)
+			}
+		})
+	}
+}
+
+func TestParse_GroupedSpans(t *testing.T) {
+	l := newLexerString(`(a + b) * (c)`, t, lexer.WithStartInCodeMode())
+	prog := parse(l, t)
+
+	infix := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+
+	if line, col := infix.Left.(ast.Spanned).End(); line != 1 || col != 8 {
+		t.Fatalf("wrong end position of left operand, expected=1:8, got=%d:%d", line, col)
+	}
+
+	if line, col := infix.Right.(ast.Spanned).End(); line != 1 || col != 14 {
+		t.Fatalf("wrong end position of right operand, expected=1:14, got=%d:%d", line, col)
+	}
+}
+
+func TestParse_InfixSpans(t *testing.T) {
+	l := newLexerString(`a + b * c`, t, lexer.WithStartInCodeMode())
+	prog := parse(l, t)
+
+	infix := prog.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+
+	if line, col := infix.Left.(ast.Spanned).End(); line != 1 || col != 2 {
+		t.Fatalf("wrong end position of left expression, expected=1:2, got=%d:%d", line, col)
+	}
+
+	right := infix.Right.(*ast.InfixExpression)
+
+	if right.Line() != 1 || right.Col() != 5 {
+		t.Fatalf("wrong start position of right expression, expected=1:5, got=%d:%d", right.Line(), right.Col())
+	}
+
+	if line, col := right.End(); line != 1 || col != 10 {
+		t.Fatalf("wrong end position of right expression, expected=1:10, got=%d:%d", line, col)
+	}
+}
+
 func TestParseExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -248,7 +701,7 @@ func TestParse(t *testing.T) {
 			`x`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
-					Expression: newIdent("x"),
+					Expression: ast.NewIdent("x"),
 				},
 			},
 		},
@@ -256,7 +709,7 @@ func TestParse(t *testing.T) {
 			`5`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
-					Expression: newIntLiteral(5),
+					Expression: ast.NewIntLiteral(5),
 				},
 			},
 		},
@@ -266,18 +719,71 @@ func TestParse(t *testing.T) {
 				&ast.ExpressionStatement{
 					Expression: &ast.PrefixExpression{
 						Operator:   "!",
-						Expression: newIdent("x"),
+						Expression: ast.NewIdent("x"),
 					},
 				},
 			},
 		},
 		{
 			`-5`,
+			[]ast.Statement{
+				&ast.ExpressionStatement{
+					Expression: ast.NewIntLiteral(-5),
+				},
+			},
+		},
+		{
+			`-9223372036854775808`,
+			[]ast.Statement{
+				&ast.ExpressionStatement{
+					Expression: ast.NewIntLiteral(math.MinInt64),
+				},
+			},
+		},
+		{
+			`--5`,
+			[]ast.Statement{
+				&ast.ExpressionStatement{
+					Expression: &ast.PrefixExpression{
+						Operator:   "-",
+						Expression: ast.NewIntLiteral(-5),
+					},
+				},
+			},
+		},
+		{
+			`-(5)`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.PrefixExpression{
 						Operator:   "-",
-						Expression: newIntLiteral(5),
+						Expression: ast.NewIntLiteral(5),
+					},
+				},
+			},
+		},
+		{
+			`+5`,
+			[]ast.Statement{
+				&ast.ExpressionStatement{
+					Expression: &ast.PrefixExpression{
+						Operator:   "+",
+						Expression: ast.NewIntLiteral(5),
+					},
+				},
+			},
+		},
+		{
+			`1 + +x`,
+			[]ast.Statement{
+				&ast.ExpressionStatement{
+					Expression: &ast.InfixExpression{
+						Left:     ast.NewIntLiteral(1),
+						Operator: "+",
+						Right: &ast.PrefixExpression{
+							Operator:   "+",
+							Expression: ast.NewIdent("x"),
+						},
 					},
 				},
 			},
@@ -287,12 +793,12 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.InfixExpression{
-						Left:     newIntLiteral(5),
+						Left:     ast.NewIntLiteral(5),
 						Operator: "+",
 						Right: &ast.InfixExpression{
-							Left:     newIntLiteral(6),
+							Left:     ast.NewIntLiteral(6),
 							Operator: "*",
-							Right:    newIntLiteral(7),
+							Right:    ast.NewIntLiteral(7),
 						},
 					},
 				},
@@ -305,7 +811,7 @@ func TestParse(t *testing.T) {
 					Ident: ast.Ident{
 						Name: "x",
 					},
-					Expression: newIntLiteral(5),
+					Expression: ast.NewIntLiteral(5),
 				},
 			},
 		},
@@ -313,7 +819,7 @@ func TestParse(t *testing.T) {
 			`true`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
-					Expression: newBoolLiteral(true),
+					Expression: ast.NewBoolLiteral(true),
 				},
 			},
 		},
@@ -321,7 +827,7 @@ func TestParse(t *testing.T) {
 			`false`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
-					Expression: newBoolLiteral(false),
+					Expression: ast.NewBoolLiteral(false),
 				},
 			},
 		},
@@ -329,7 +835,7 @@ func TestParse(t *testing.T) {
 			`nil`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
-					Expression: newNilLiteral(),
+					Expression: ast.NewNilLiteral(),
 				},
 			},
 		},
@@ -340,7 +846,7 @@ func TestParse(t *testing.T) {
 					Ident: ast.Ident{
 						Name: "x",
 					},
-					Expression: newIntLiteral(5),
+					Expression: ast.NewIntLiteral(5),
 				},
 			},
 		},
@@ -355,14 +861,14 @@ func TestParse(t *testing.T) {
 						Conditionals: []ast.ConditionalBlock{
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("x"),
+									Left:     ast.NewIdent("x"),
 									Operator: "==",
-									Right:    newIntLiteral(5),
+									Right:    ast.NewIntLiteral(5),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("y"),
+											Expression: ast.NewIdent("y"),
 										},
 									},
 								},
@@ -384,28 +890,28 @@ func TestParse(t *testing.T) {
 						Conditionals: []ast.ConditionalBlock{
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("a"),
+									Left:     ast.NewIdent("a"),
 									Operator: "==",
-									Right:    newIntLiteral(5),
+									Right:    ast.NewIntLiteral(5),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("b"),
+											Expression: ast.NewIdent("b"),
 										},
 									},
 								},
 							},
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("c"),
+									Left:     ast.NewIdent("c"),
 									Operator: "==",
-									Right:    newIntLiteral(6),
+									Right:    ast.NewIntLiteral(6),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("d"),
+											Expression: ast.NewIdent("d"),
 										},
 									},
 								},
@@ -429,42 +935,42 @@ func TestParse(t *testing.T) {
 						Conditionals: []ast.ConditionalBlock{
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("a"),
+									Left:     ast.NewIdent("a"),
 									Operator: "==",
-									Right:    newIntLiteral(5),
+									Right:    ast.NewIntLiteral(5),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("b"),
+											Expression: ast.NewIdent("b"),
 										},
 									},
 								},
 							},
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("c"),
+									Left:     ast.NewIdent("c"),
 									Operator: "==",
-									Right:    newIntLiteral(6),
+									Right:    ast.NewIntLiteral(6),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("d"),
+											Expression: ast.NewIdent("d"),
 										},
 									},
 								},
 							},
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("e"),
+									Left:     ast.NewIdent("e"),
 									Operator: "==",
-									Right:    newIntLiteral(7),
+									Right:    ast.NewIntLiteral(7),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("f"),
+											Expression: ast.NewIdent("f"),
 										},
 									},
 								},
@@ -490,42 +996,42 @@ func TestParse(t *testing.T) {
 						Conditionals: []ast.ConditionalBlock{
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("a"),
+									Left:     ast.NewIdent("a"),
 									Operator: "==",
-									Right:    newIntLiteral(5),
+									Right:    ast.NewIntLiteral(5),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("b"),
+											Expression: ast.NewIdent("b"),
 										},
 									},
 								},
 							},
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("c"),
+									Left:     ast.NewIdent("c"),
 									Operator: "==",
-									Right:    newIntLiteral(6),
+									Right:    ast.NewIntLiteral(6),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("d"),
+											Expression: ast.NewIdent("d"),
 										},
 									},
 								},
 							},
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("e"),
+									Left:     ast.NewIdent("e"),
 									Operator: "==",
-									Right:    newIntLiteral(7),
+									Right:    ast.NewIntLiteral(7),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("f"),
+											Expression: ast.NewIdent("f"),
 										},
 									},
 								},
@@ -534,7 +1040,7 @@ func TestParse(t *testing.T) {
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("g"),
+											Expression: ast.NewIdent("g"),
 										},
 									},
 								},
@@ -556,14 +1062,14 @@ func TestParse(t *testing.T) {
 						Conditionals: []ast.ConditionalBlock{
 							{
 								Condition: &ast.InfixExpression{
-									Left:     newIdent("a"),
+									Left:     ast.NewIdent("a"),
 									Operator: "==",
-									Right:    newIntLiteral(5),
+									Right:    ast.NewIntLiteral(5),
 								},
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("b"),
+											Expression: ast.NewIdent("b"),
 										},
 									},
 								},
@@ -572,7 +1078,7 @@ func TestParse(t *testing.T) {
 								Block: ast.Block{
 									Statements: []ast.Statement{
 										&ast.ExpressionStatement{
-											Expression: newIdent("c"),
+											Expression: ast.NewIdent("c"),
 										},
 									},
 								},
@@ -587,7 +1093,7 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.CallExpression{
-						Callee: newIdent("x"),
+						Callee: ast.NewIdent("x"),
 					},
 				},
 			},
@@ -597,9 +1103,9 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.CallExpression{
-						Callee: newIdent("x"),
+						Callee: ast.NewIdent("x"),
 						Params: []ast.Expression{
-							newIdent("y"),
+							ast.NewIdent("y"),
 						},
 					},
 				},
@@ -610,10 +1116,10 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.CallExpression{
-						Callee: newIdent("x"),
+						Callee: ast.NewIdent("x"),
 						Params: []ast.Expression{
-							newIdent("y"),
-							newIdent("z"),
+							ast.NewIdent("y"),
+							ast.NewIdent("z"),
 						},
 					},
 				},
@@ -624,22 +1130,22 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.CallExpression{
-						Callee: newIdent("x"),
+						Callee: ast.NewIdent("x"),
 						Params: []ast.Expression{
 							&ast.InfixExpression{
-								Left:     newIntLiteral(1),
+								Left:     ast.NewIntLiteral(1),
 								Operator: "*",
-								Right:    newIntLiteral(2),
+								Right:    ast.NewIntLiteral(2),
 							},
 							&ast.InfixExpression{
-								Left:     newIntLiteral(3),
+								Left:     ast.NewIntLiteral(3),
 								Operator: "+",
-								Right:    newIntLiteral(4),
+								Right:    ast.NewIntLiteral(4),
 							},
 							&ast.InfixExpression{
-								Left:     newIntLiteral(5),
+								Left:     ast.NewIntLiteral(5),
 								Operator: "/",
-								Right:    newIdent("y"),
+								Right:    ast.NewIdent("y"),
 							},
 						},
 					},
@@ -651,9 +1157,9 @@ func TestParse(t *testing.T) {
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.InfixExpression{
-						Left:     newStringLiteral("abc"),
+						Left:     ast.NewStringLiteral("abc"),
 						Operator: "==",
-						Right:    newStringLiteral("def"),
+						Right:    ast.NewStringLiteral("def"),
 					},
 				},
 			},
@@ -664,13 +1170,13 @@ func TestParse(t *testing.T) {
 				&ast.ExpressionStatement{
 					Expression: &ast.InfixExpression{
 						Left: &ast.FieldExpression{
-							Callee: newIdent("a"),
-							Index:  newStringLiteral("b"),
+							Callee: ast.NewIdent("a"),
+							Index:  ast.NewStringLiteral("b"),
 						},
 						Operator: "==",
 						Right: &ast.FieldExpression{
-							Callee: newIdent("x"),
-							Index:  newStringLiteral("y"),
+							Callee: ast.NewIdent("x"),
+							Index:  ast.NewStringLiteral("y"),
 						},
 					},
 				},
@@ -682,13 +1188,13 @@ func TestParse(t *testing.T) {
 				&ast.ExpressionStatement{
 					Expression: &ast.InfixExpression{
 						Left: &ast.FieldExpression{
-							Callee: newIdent("a"),
-							Index:  newStringLiteral("b"),
+							Callee: ast.NewIdent("a"),
+							Index:  ast.NewStringLiteral("b"),
 						},
 						Operator: "!=",
 						Right: &ast.FieldExpression{
-							Callee: newIdent("x"),
-							Index:  newStringLiteral("y"),
+							Callee: ast.NewIdent("x"),
+							Index:  ast.NewStringLiteral("y"),
 						},
 					},
 				},
@@ -701,12 +1207,12 @@ func TestParse(t *testing.T) {
 					Expression: &ast.FieldExpression{
 						Callee: &ast.FieldExpression{
 							Callee: &ast.FieldExpression{
-								Callee: newIdent("a"),
-								Index:  newStringLiteral("b"),
+								Callee: ast.NewIdent("a"),
+								Index:  ast.NewStringLiteral("b"),
 							},
-							Index: newStringLiteral("c"),
+							Index: ast.NewStringLiteral("c"),
 						},
-						Index: newStringLiteral("d"),
+						Index: ast.NewStringLiteral("d"),
 					},
 				},
 			},
@@ -718,12 +1224,12 @@ func TestParse(t *testing.T) {
 					Expression: &ast.FieldExpression{
 						Callee: &ast.FieldExpression{
 							Callee: &ast.FieldExpression{
-								Callee: newIdent("a"),
-								Index:  newStringLiteral("b"),
+								Callee: ast.NewIdent("a"),
+								Index:  ast.NewStringLiteral("b"),
 							},
-							Index: newStringLiteral("c"),
+							Index: ast.NewStringLiteral("c"),
 						},
-						Index: newStringLiteral("d"),
+						Index: ast.NewStringLiteral("d"),
 					},
 				},
 			},
@@ -735,12 +1241,12 @@ func TestParse(t *testing.T) {
 					Expression: &ast.FieldExpression{
 						Callee: &ast.FieldExpression{
 							Callee: &ast.FieldExpression{
-								Callee: newIdent("a"),
-								Index:  newStringLiteral("b"),
+								Callee: ast.NewIdent("a"),
+								Index:  ast.NewStringLiteral("b"),
 							},
-							Index: newStringLiteral("c"),
+							Index: ast.NewStringLiteral("c"),
 						},
-						Index: newStringLiteral("d"),
+						Index: ast.NewStringLiteral("d"),
 					},
 				},
 			},
@@ -752,12 +1258,12 @@ func TestParse(t *testing.T) {
 					Expression: &ast.FieldExpression{
 						Callee: &ast.FieldExpression{
 							Callee: &ast.FieldExpression{
-								Callee: newIdent("a"),
-								Index:  newStringLiteral("b"),
+								Callee: ast.NewIdent("a"),
+								Index:  ast.NewStringLiteral("b"),
 							},
-							Index: newStringLiteral("c"),
+							Index: ast.NewStringLiteral("c"),
 						},
-						Index: newStringLiteral("d"),
+						Index: ast.NewStringLiteral("d"),
 					},
 				},
 			},
@@ -770,39 +1276,125 @@ func TestParse(t *testing.T) {
 						Callee: &ast.FieldExpression{
 							Callee: &ast.CallExpression{
 								Callee: &ast.FieldExpression{
-									Callee: newIdent("a"),
-									Index:  newStringLiteral("b"),
+									Callee: ast.NewIdent("a"),
+									Index:  ast.NewStringLiteral("b"),
 								},
 								Params: []ast.Expression{
-									newIdent("x"),
+									ast.NewIdent("x"),
 								},
 							},
-							Index: newStringLiteral("c"),
+							Index: ast.NewStringLiteral("c"),
 						},
-						Index: newStringLiteral("d"),
+						Index: ast.NewStringLiteral("d"),
 					},
 				},
 			},
 		},
+		{
+			`{
+				"x": 42,
+				"y": "foo",
+			}`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(&ast.HashExpression{
+					Values: map[string]ast.Expression{
+						"x": ast.NewIntLiteral(42),
+						"y": ast.NewStringLiteral("foo"),
+					},
+				}),
+			},
+		},
+		{
+			`{ x: 1, "y-z": 2, if_: 3 }`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(&ast.HashExpression{
+					Values: map[string]ast.Expression{
+						"x":   ast.NewIntLiteral(1),
+						"y-z": ast.NewIntLiteral(2),
+						"if_": ast.NewIntLiteral(3),
+					},
+				}),
+			},
+		},
+		{
+			`x(
+				a,
+				b,
+			)`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(ast.NewCall(ast.NewIdent("x"), ast.NewIdent("a"), ast.NewIdent("b"))),
+			},
+		},
 		{
 			`{ "x": 42, "y": "foo" }`,
 			[]ast.Statement{
 				&ast.ExpressionStatement{
 					Expression: &ast.HashExpression{
 						Values: map[string]ast.Expression{
-							"x": newIntLiteral(42),
-							"y": newStringLiteral("foo"),
+							"x": ast.NewIntLiteral(42),
+							"y": ast.NewStringLiteral("foo"),
 						},
 					},
 				},
 			},
 		},
 		{
-			`break
-			continue`,
+			`for i in x
+				break
+				continue
+			end`,
 			[]ast.Statement{
-				&ast.BreakStatement{},
-				&ast.ContinueStatement{},
+				ast.NewExpressionStatement(&ast.ForExpression{
+					Ident:     *ast.NewIdent("i"),
+					RangeExpr: ast.NewIdent("x"),
+					Block: ast.Block{
+						Statements: []ast.Statement{
+							&ast.BreakStatement{},
+							&ast.ContinueStatement{},
+						},
+					},
+				}),
+			},
+		},
+		{
+			`for k: v, st in x
+				"foo"
+			end`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(&ast.ForExpression{
+					Ident:       *ast.NewIdent("k"),
+					ValueIdent:  ast.NewIdent("v"),
+					StatusIdent: ast.NewIdent("st"),
+					RangeExpr:   ast.NewIdent("x"),
+					Block: ast.Block{
+						Statements: []ast.Statement{
+							ast.NewExpressionStatement(ast.NewStringLiteral("foo")),
+						},
+					},
+				}),
+			},
+		},
+		{
+			`for i in x
+				"foo"
+			else
+				"bar"
+			end`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(&ast.ForExpression{
+					Ident:     *ast.NewIdent("i"),
+					RangeExpr: ast.NewIdent("x"),
+					Block: ast.Block{
+						Statements: []ast.Statement{
+							ast.NewExpressionStatement(ast.NewStringLiteral("foo")),
+						},
+					},
+					ElseBlock: &ast.Block{
+						Statements: []ast.Statement{
+							ast.NewExpressionStatement(ast.NewStringLiteral("bar")),
+						},
+					},
+				}),
 			},
 		},
 		{
@@ -816,15 +1408,15 @@ func TestParse(t *testing.T) {
 							Name: "i",
 						},
 						RangeExpr: &ast.CallExpression{
-							Callee: newIdent("range"),
+							Callee: ast.NewIdent("range"),
 							Params: []ast.Expression{
-								newIdent("x"),
+								ast.NewIdent("x"),
 							},
 						},
 						Block: ast.Block{
 							Statements: []ast.Statement{
 								&ast.ExpressionStatement{
-									Expression: newStringLiteral("foo"),
+									Expression: ast.NewStringLiteral("foo"),
 								},
 							},
 						},
@@ -846,15 +1438,15 @@ func TestParse(t *testing.T) {
 							Name: "st",
 						},
 						RangeExpr: &ast.CallExpression{
-							Callee: newIdent("range"),
+							Callee: ast.NewIdent("range"),
 							Params: []ast.Expression{
-								newIdent("x"),
+								ast.NewIdent("x"),
 							},
 						},
 						Block: ast.Block{
 							Statements: []ast.Statement{
 								&ast.ExpressionStatement{
-									Expression: newStringLiteral("foo"),
+									Expression: ast.NewStringLiteral("foo"),
 								},
 							},
 						},
@@ -862,6 +1454,22 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			`do
+			  let a = 5
+			  a + 1
+			end`,
+			[]ast.Statement{
+				ast.NewExpressionStatement(&ast.DoExpression{
+					Block: ast.Block{
+						Statements: []ast.Statement{
+							ast.NewLet("a", ast.NewIntLiteral(5)),
+							ast.NewExpressionStatement(ast.NewInfix(ast.NewIdent("a"), "+", ast.NewIntLiteral(1))),
+						},
+					},
+				}),
+			},
+		},
 		{
 			`capture
 			  "foo"
@@ -872,7 +1480,7 @@ func TestParse(t *testing.T) {
 						Block: ast.Block{
 							Statements: []ast.Statement{
 								&ast.ExpressionStatement{
-									Expression: newStringLiteral("foo"),
+									Expression: ast.NewStringLiteral("foo"),
 								},
 							},
 						},
@@ -889,6 +1497,27 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParse_ForSameIdents(t *testing.T) {
+	l := newLexerString("for i, i in range(x) end", t, lexer.WithStartInCodeMode())
+	tCh, doneCh := l.Tokens()
+
+	p := New(tCh, doneCh)
+
+	_, err := p.Parse()
+	if err == nil {
+		t.Fatalf("expected error parsing program")
+	}
+
+	if !IsParseError(err) {
+		t.Fatalf("expected parse error, got: %v", err)
+	}
+
+	expected := "parse error at line 1, column 8: loop and status identifier must differ: i"
+	if err.Error() != expected {
+		t.Fatalf("wrong error, expected=%q, got=%q", expected, err.Error())
+	}
+}
+
 func testStatement(actual ast.Statement, expected ast.Statement, t *testing.T) {
 	t.Helper()
 
@@ -905,6 +1534,17 @@ func testStatement(actual ast.Statement, expected ast.Statement, t *testing.T) {
 		// okay
 	case *ast.ContinueStatement:
 		// okay
+	case *ast.WhitespaceStatement:
+		if actual.(*ast.WhitespaceStatement).Trim != ex.Trim {
+			t.Fatalf("wrong whitespace statement, expected trim=%t, got trim=%t", ex.Trim, actual.(*ast.WhitespaceStatement).Trim)
+		}
+	case *ast.AssignStatement:
+		testFieldExpression(actual.(*ast.AssignStatement).Target, ex.Target, t)
+		testExpression(actual.(*ast.AssignStatement).Expression, ex.Expression, t)
+	case *ast.ExtendsStatement:
+		if actual.(*ast.ExtendsStatement).Name != ex.Name {
+			t.Fatalf("wrong extends statement, expected name=%s, got name=%s", ex.Name, actual.(*ast.ExtendsStatement).Name)
+		}
 	default:
 		t.Fatalf("unknown statement type: %T", expected)
 	}
@@ -945,6 +1585,8 @@ func testExpression(actual ast.Expression, expected ast.Expression, t *testing.T
 		// okay
 	case *ast.IfExpression:
 		testIfExpression(actual.(*ast.IfExpression), ex, t)
+	case *ast.SwitchExpression:
+		testSwitchExpression(actual.(*ast.SwitchExpression), ex, t)
 	case *ast.InfixExpression:
 		testInfixExpression(actual.(*ast.InfixExpression), ex, t)
 	case *ast.CallExpression:
@@ -957,6 +1599,10 @@ func testExpression(actual ast.Expression, expected ast.Expression, t *testing.T
 		testForExpression(actual.(*ast.ForExpression), ex, t)
 	case *ast.CaptureExpression:
 		testCaptureExpression(actual.(*ast.CaptureExpression), ex, t)
+	case *ast.DoExpression:
+		testDoExpression(actual.(*ast.DoExpression), ex, t)
+	case *ast.BlockExpression:
+		testBlockExpression(actual.(*ast.BlockExpression), ex, t)
 	case *ast.HashExpression:
 		testHashExpression(actual.(*ast.HashExpression), ex, t)
 	default:
@@ -1023,6 +1669,39 @@ func testConditionalBlock(actual *ast.ConditionalBlock, expected *ast.Conditiona
 	testBlock(&actual.Block, &expected.Block, t)
 }
 
+func testSwitchExpression(actual *ast.SwitchExpression, expected *ast.SwitchExpression, t *testing.T) {
+	t.Helper()
+
+	testExpression(actual.Subject, expected.Subject, t)
+
+	if len(actual.Cases) != len(expected.Cases) {
+		t.Fatalf("wrong number of cases in switch-expression, expected=%d, got=%d",
+			len(expected.Cases), len(actual.Cases))
+	}
+
+	for i := range expected.Cases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			testCaseBlock(&actual.Cases[i], &expected.Cases[i], t)
+		})
+	}
+}
+
+func testCaseBlock(actual *ast.CaseBlock, expected *ast.CaseBlock, t *testing.T) {
+	t.Helper()
+
+	if actual.Operator != expected.Operator {
+		t.Fatalf("wrong operator of case-block, expected=%s, got=%s", expected.Operator, actual.Operator)
+	}
+
+	if actual.Value != nil && expected.Value != nil {
+		testExpression(actual.Value, expected.Value, t)
+	} else if (actual.Value == nil && expected.Value != nil) || (actual.Value != nil && expected.Value == nil) {
+		t.Fatal("cannot compare value of case-block, one of them is nil")
+	}
+
+	testBlock(&actual.Block, &expected.Block, t)
+}
+
 func testBlock(actual *ast.Block, expected *ast.Block, t *testing.T) {
 	t.Helper()
 
@@ -1096,11 +1775,21 @@ func testForExpression(actual *ast.ForExpression, expected *ast.ForExpression, t
 	t.Helper()
 
 	testIdentifier(&actual.Ident, &expected.Ident, t)
+	if actual.ValueIdent != nil || expected.ValueIdent != nil {
+		testIdentifier(actual.ValueIdent, expected.ValueIdent, t)
+	}
 	if actual.StatusIdent != nil || expected.StatusIdent != nil {
 		testIdentifier(actual.StatusIdent, expected.StatusIdent, t)
 	}
 	testExpression(actual.RangeExpr, expected.RangeExpr, t)
 	testBlock(&actual.Block, &expected.Block, t)
+
+	if (actual.ElseBlock == nil) != (expected.ElseBlock == nil) {
+		t.Fatalf("wrong else block, expected=%v, got=%v", expected.ElseBlock, actual.ElseBlock)
+	}
+	if expected.ElseBlock != nil {
+		testBlock(actual.ElseBlock, expected.ElseBlock, t)
+	}
 }
 
 func testCaptureExpression(actual *ast.CaptureExpression, expected *ast.CaptureExpression, t *testing.T) {
@@ -1109,6 +1798,22 @@ func testCaptureExpression(actual *ast.CaptureExpression, expected *ast.CaptureE
 	testBlock(&actual.Block, &expected.Block, t)
 }
 
+func testDoExpression(actual *ast.DoExpression, expected *ast.DoExpression, t *testing.T) {
+	t.Helper()
+
+	testBlock(&actual.Block, &expected.Block, t)
+}
+
+func testBlockExpression(actual *ast.BlockExpression, expected *ast.BlockExpression, t *testing.T) {
+	t.Helper()
+
+	if actual.Name != expected.Name {
+		t.Fatalf("wrong block name, expected=%s, got=%s", expected.Name, actual.Name)
+	}
+
+	testBlock(&actual.Block, &expected.Block, t)
+}
+
 func testHashExpression(actual *ast.HashExpression, expected *ast.HashExpression, t *testing.T) {
 	t.Helper()
 
@@ -1159,37 +1864,3 @@ func newLexerString(s string, tb testing.TB, opts ...lexer.Opt) *lexer.Lexer {
 	r := bytes.NewReader([]byte(s))
 	return lexer.New(r, opts...)
 }
-
-func newIdent(n string) *ast.Ident {
-	return &ast.Ident{
-		Name: n,
-	}
-}
-
-func newStringLiteral(s string) *ast.StringLiteral {
-	return &ast.StringLiteral{
-		Value: s,
-	}
-}
-
-func newBoolLiteral(b bool) *ast.BoolLiteral {
-	return &ast.BoolLiteral{
-		Value: b,
-	}
-}
-
-func newIntLiteral(i int64) *ast.IntLiteral {
-	return &ast.IntLiteral{
-		Value: i,
-	}
-}
-
-func newLiteral(t string) *ast.Literal {
-	return &ast.Literal{
-		Text: t,
-	}
-}
-
-func newNilLiteral() *ast.NilLiteral {
-	return &ast.NilLiteral{}
-}
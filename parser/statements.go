@@ -13,12 +13,22 @@ func (p *Parser) parseStatement() (ast.Statement, error) {
 		return p.parseBreakStatement()
 	case lexer.Continue:
 		return p.parseContinueStatement()
+	case lexer.Extends:
+		return p.parseExtendsStatement()
+	case lexer.Ident:
+		if p.isAtWhitespaceStatement() {
+			return p.parseWhitespaceStatement()
+		}
+		if _, ok := compoundAssignOperators[p.nextToken.Type]; ok {
+			return p.parseCompoundAssignStatement()
+		}
+		return p.parseExpressionOrAssignStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
+func (p *Parser) parseLetStatement() (ast.Statement, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
 
@@ -26,6 +36,10 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 		return nil, err
 	}
 
+	if p.nextTokenIs(lexer.Dot) || p.nextTokenIs(lexer.LeftBracket) {
+		return p.parseAssignStatement(line, col)
+	}
+
 	name := p.currToken.Literal
 
 	if err := p.expectNext(lexer.Assign); err != nil {
@@ -36,7 +50,7 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 		return nil, err
 	}
 
-	expr, err := p.parseExpression(precedenceLowest)
+	expr, err := p.parseExpression(PrecedenceLowest)
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +62,101 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 			Name:      name,
 		},
 		Expression: expr,
+		Span: ast.Span{
+			EndLine: p.prevToken.EndLine,
+			EndCol:  p.prevToken.EndCol,
+		},
+	}, nil
+}
+
+// parseAssignStatement parses the remainder of a let statement that assigns to a field expression, starting at
+// the identifier of the field expression's callee.
+func (p *Parser) parseAssignStatement(line int, col int) (*ast.AssignStatement, error) {
+	targetLine := p.currToken.Line
+	targetCol := p.currToken.Col
+
+	target, err := p.parseExpression(PrecedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseAssignment(line, col, targetLine, targetCol, target)
+}
+
+// parseAssignment parses the remainder of an assignment to target, starting at the assign token.
+func (p *Parser) parseAssignment(line int, col int, targetLine int, targetCol int, target ast.Expression) (*ast.AssignStatement, error) {
+	field, ok := target.(*ast.FieldExpression)
+	if !ok {
+		return nil, newParseErrorf(targetLine, targetCol, "field or index expression expected as assignment target")
+	}
+
+	if !p.currTokenIs(lexer.Assign) {
+		return nil, p.newUnexpectedTokenError(lexer.Assign)
+	}
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpression(PrecedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.AssignStatement{
+		StartLine:  line,
+		StartCol:   col,
+		Target:     field,
+		Expression: expr,
+	}, nil
+}
+
+// parseCompoundAssignStatement parses a compound assignment such as "x += y" into a let statement that assigns
+// the corresponding infix expression, such as "x + y", to the identifier.
+func (p *Parser) parseCompoundAssignStatement() (*ast.LetStatement, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+	name := p.currToken.Literal
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	op := compoundAssignOperators[p.currToken.Type]
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpression(PrecedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	end := ast.Span{
+		EndLine: p.prevToken.EndLine,
+		EndCol:  p.prevToken.EndCol,
+	}
+
+	return &ast.LetStatement{
+		Ident: ast.Ident{
+			StartLine: line,
+			StartCol:  col,
+			Name:      name,
+		},
+		Expression: &ast.InfixExpression{
+			StartLine: line,
+			StartCol:  col,
+			Left: &ast.Ident{
+				StartLine: line,
+				StartCol:  col,
+				Name:      name,
+			},
+			Operator: op,
+			Right:    expr,
+			Span:     end,
+		},
+		Span: end,
 	}, nil
 }
 
@@ -55,6 +164,10 @@ func (p *Parser) parseBreakStatement() (*ast.BreakStatement, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
 
+	if p.loopLevel <= 0 {
+		return nil, newParseErrorf(line, col, "break outside of loop")
+	}
+
 	if err := p.readNextToken(); err != nil {
 		return nil, err
 	}
@@ -69,6 +182,10 @@ func (p *Parser) parseContinueStatement() (*ast.ContinueStatement, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
 
+	if p.loopLevel <= 0 {
+		return nil, newParseErrorf(line, col, "continue outside of loop")
+	}
+
 	if err := p.readNextToken(); err != nil {
 		return nil, err
 	}
@@ -79,11 +196,88 @@ func (p *Parser) parseContinueStatement() (*ast.ContinueStatement, error) {
 	}, nil
 }
 
+func (p *Parser) parseExtendsStatement() (*ast.ExtendsStatement, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	if p.haveExtends {
+		return nil, newParseErrorf(line, col, "duplicate extends statement")
+	}
+
+	p.haveExtends = true
+
+	if err := p.expectNext(lexer.String); err != nil {
+		return nil, err
+	}
+
+	name := p.currToken.Literal
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	return &ast.ExtendsStatement{
+		StartLine: line,
+		StartCol:  col,
+		Name:      name,
+	}, nil
+}
+
+// isAtWhitespaceStatement returns whether the current token starts a whitespace statement, such as
+// "whitespace trim". "whitespace" is not a keyword, so it can still be used as an identifier otherwise.
+func (p *Parser) isAtWhitespaceStatement() bool {
+	return p.currToken.Literal == "whitespace" && p.nextTokenIs(lexer.Ident) &&
+		(p.nextToken.Literal == "trim" || p.nextToken.Literal == "keep")
+}
+
+func (p *Parser) parseWhitespaceStatement() (*ast.WhitespaceStatement, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	p.trimLiterals = p.currToken.Literal == "trim"
+
+	if err := p.readNextToken(); err != nil {
+		return nil, err
+	}
+
+	return &ast.WhitespaceStatement{
+		StartLine: line,
+		StartCol:  col,
+		Trim:      p.trimLiterals,
+	}, nil
+}
+
+// parseExpressionOrAssignStatement parses an expression statement, or an assignment to a field expression
+// without the let keyword, such as `h["key"] = x`.
+func (p *Parser) parseExpressionOrAssignStatement() (ast.Statement, error) {
+	line := p.currToken.Line
+	col := p.currToken.Col
+
+	expr, err := p.parseExpression(PrecedenceLowest)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.currTokenIs(lexer.Assign) {
+		return p.parseAssignment(line, col, line, col, expr)
+	}
+
+	return &ast.ExpressionStatement{
+		StartLine:  line,
+		StartCol:   col,
+		Expression: expr,
+	}, nil
+}
+
 func (p *Parser) parseExpressionStatement() (*ast.ExpressionStatement, error) {
 	line := p.currToken.Line
 	col := p.currToken.Col
 
-	expr, err := p.parseExpression(precedenceLowest)
+	expr, err := p.parseExpression(PrecedenceLowest)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // Ranger iterates over a set of values and returns the current value for each iteration.
@@ -27,6 +28,12 @@ type Status struct {
 	Even    bool
 	Odd     bool
 	HasMore bool
+
+	// Number is the 1-based position of the current iteration, that is, Index+1.
+	Number int
+
+	// Total is the total number of iterations, or -1 if the ranger cannot know it in advance.
+	Total int
 }
 
 type HashEntry struct {
@@ -34,6 +41,15 @@ type HashEntry struct {
 	Value interface{}
 }
 
+// Pair is a pair of values, such as produced by a ranger returned by NewZip.
+//
+// When a for loop ranges over Pair values and specifies two identifiers, the pair is destructured, binding
+// First and Second to the identifiers, instead of binding the loop status to the second identifier.
+type Pair struct {
+	First  interface{}
+	Second interface{}
+}
+
 type intRanger struct {
 	minInclusive int
 	maxExclusive int
@@ -45,14 +61,43 @@ type sliceRanger struct {
 	index int
 }
 
+type chanRanger struct {
+	ch    reflect.Value
+	value interface{}
+	index int
+}
+
+type funcRanger struct {
+	next  func() (interface{}, bool)
+	value interface{}
+	index int
+}
+
+type filterRanger struct {
+	r     Ranger
+	keep  func(v interface{}) bool
+	value interface{}
+	last  bool
+	index int
+}
+
+type mapRanger struct {
+	r  Ranger
+	fn func(v interface{}) interface{}
+}
+
 type hashRanger struct {
 	h     map[string]interface{}
 	keys  []string
 	index int
 }
 
-// New returns a ranger that iterates over a slice, an array, or a hash. New panics if v is nil, or if it is of another type.
-// If v is a hash, the ranger will produce HashEntry elements.
+// New returns a ranger that iterates over a slice, an array, a hash, the exported fields of a struct,
+// or the characters of a string. New panics if v is nil, or if it is of another type.
+// If v is a hash, the ranger will produce HashEntry elements, sorted by key.
+// If v is a struct or a pointer to a struct, the ranger will produce HashEntry elements for the struct's
+// exported fields, in the order of their declaration.
+// If v is a string, the ranger will produce each character (rune) as a string.
 func New(v interface{}) Ranger {
 	if h, ok := v.(map[string]interface{}); ok {
 		return &hashRanger{
@@ -62,6 +107,21 @@ func New(v interface{}) Ranger {
 		}
 	}
 
+	if h, keys, ok := structFields(v); ok {
+		return &hashRanger{
+			h:     h,
+			keys:  keys,
+			index: -1,
+		}
+	}
+
+	if s, ok := runes(v); ok {
+		return &sliceRanger{
+			s:     s,
+			index: -1,
+		}
+	}
+
 	if s, err := toSlice(v); err != nil {
 		panic(err)
 	} else {
@@ -72,6 +132,89 @@ func New(v interface{}) Ranger {
 	}
 }
 
+// NewZip returns a ranger that iterates over the elements of the slices or arrays a and b simultaneously,
+// producing Pair elements. The ranger stops when either a or b has no more elements. NewZip panics if
+// a or b is nil, or if they are of another type.
+func NewZip(a interface{}, b interface{}) Ranger {
+	as, err := toSlice(a)
+	if err != nil {
+		panic(err)
+	}
+
+	bs, err := toSlice(b)
+	if err != nil {
+		panic(err)
+	}
+
+	l := len(as)
+	if len(bs) < l {
+		l = len(bs)
+	}
+
+	s := make([]interface{}, l)
+	for i := range s {
+		s[i] = Pair{
+			First:  as[i],
+			Second: bs[i],
+		}
+	}
+
+	return &sliceRanger{
+		s:     s,
+		index: -1,
+	}
+}
+
+// NewChan returns a ranger that iterates over the values received from the channel ch. Next blocks until
+// a value is received, and returns false when ch has been closed. Because the number of remaining values
+// cannot be known in advance, the ranger's Status always reports Last and HasMore as false, and Total as -1.
+// NewChan panics if ch is not a channel that can be received from.
+func NewChan(ch interface{}) Ranger {
+	value := reflect.ValueOf(ch)
+	if value.Kind() != reflect.Chan || value.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Errorf("cannot receive from unsupported type: %T", ch))
+	}
+
+	return &chanRanger{
+		ch:    value,
+		index: -1,
+	}
+}
+
+// NewFunc returns a ranger that iterates over the values produced by next. next is called each time Next
+// is called, and returns the next value and whether that value is valid. The ranger stops at the first value
+// that is not valid. Because the number of remaining values cannot be known in advance, the ranger's Status
+// always reports Last and HasMore as false, and Total as -1.
+func NewFunc(next func() (interface{}, bool)) Ranger {
+	return &funcRanger{
+		next:  next,
+		index: -1,
+	}
+}
+
+// NewFilter returns a ranger that iterates over the values of r, skipping all values for which keep returns false.
+// The ranger's Status reflects the filtered values, that is, the indexes of the values produced are contiguous.
+// Next only reads as many values from r as needed to find the next value to keep, so it never blocks on values
+// beyond that, for example when r is a ranger returned by NewChan. Because the ranger does not look ahead, the
+// number of remaining values cannot be known: its Status reports Last only if r reports Last for the current
+// value, always reports HasMore as false, and Total as -1.
+func NewFilter(r Ranger, keep func(v interface{}) bool) Ranger {
+	return &filterRanger{
+		r:     r,
+		keep:  keep,
+		index: -1,
+	}
+}
+
+// NewMap returns a ranger that iterates over the values of r, producing the result of fn for each value.
+// fn is called each time Value is called. The ranger's Status is the same as the Status of r.
+func NewMap(r Ranger, fn func(v interface{}) interface{}) Ranger {
+	return &mapRanger{
+		r:  r,
+		fn: fn,
+	}
+}
+
 // NewInt returns a Ranger that iterates over a range of integer values. NewInt panics if maxExclusive is not
 // greater than or equal to minInclusive.
 func NewInt(minInclusive int, maxExclusive int) Ranger {
@@ -119,6 +262,8 @@ func (i *intRanger) Status() Status {
 		Even:    even,
 		Odd:     !even,
 		HasMore: index < lastIndex,
+		Number:  index + 1,
+		Total:   lastIndex + 1,
 	}
 }
 
@@ -148,9 +293,117 @@ func (s *sliceRanger) Status() Status {
 		Even:    even,
 		Odd:     !even,
 		HasMore: s.index < lastIndex,
+		Number:  s.index + 1,
+		Total:   len(s.s),
+	}
+}
+
+// Next implements Ranger.
+func (c *chanRanger) Next() bool {
+	v, ok := c.ch.Recv()
+	if !ok {
+		return false
+	}
+	c.value = v.Interface()
+	c.index++
+	return true
+}
+
+// Value implements Ranger.
+func (c *chanRanger) Value() interface{} {
+	return c.value
+}
+
+// Status implements Ranger.
+func (c *chanRanger) Status() Status {
+	even := c.index%2 == 0
+	return Status{
+		Index:  c.index,
+		First:  c.index == 0,
+		Even:   even,
+		Odd:    !even,
+		Number: c.index + 1,
+		Total:  -1,
+	}
+}
+
+// Next implements Ranger.
+func (f *funcRanger) Next() bool {
+	v, ok := f.next()
+	if !ok {
+		return false
+	}
+	f.value = v
+	f.index++
+	return true
+}
+
+// Value implements Ranger.
+func (f *funcRanger) Value() interface{} {
+	return f.value
+}
+
+// Status implements Ranger.
+func (f *funcRanger) Status() Status {
+	even := f.index%2 == 0
+	return Status{
+		Index:  f.index,
+		First:  f.index == 0,
+		Even:   even,
+		Odd:    !even,
+		Number: f.index + 1,
+		Total:  -1,
+	}
+}
+
+// Next implements Ranger.
+func (f *filterRanger) Next() bool {
+	for f.r.Next() {
+		if v := f.r.Value(); f.keep(v) {
+			f.value = v
+			f.last = f.r.Status().Last
+			f.index++
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value implements Ranger.
+func (f *filterRanger) Value() interface{} {
+	return f.value
+}
+
+// Status implements Ranger.
+func (f *filterRanger) Status() Status {
+	even := f.index%2 == 0
+	return Status{
+		Index:  f.index,
+		First:  f.index == 0,
+		Last:   f.last,
+		Even:   even,
+		Odd:    !even,
+		Number: f.index + 1,
+		Total:  -1,
 	}
 }
 
+// Next implements Ranger.
+func (m *mapRanger) Next() bool {
+	return m.r.Next()
+}
+
+// Value implements Ranger.
+func (m *mapRanger) Value() interface{} {
+	return m.fn(m.r.Value())
+}
+
+// Status implements Ranger.
+func (m *mapRanger) Status() Status {
+	return m.r.Status()
+}
+
 // Next implements Ranger.
 func (h *hashRanger) Next() bool {
 	i := h.index + 1
@@ -181,6 +434,8 @@ func (h *hashRanger) Status() Status {
 		Even:    even,
 		Odd:     !even,
 		HasMore: h.index < lastIndex,
+		Number:  h.index + 1,
+		Total:   len(h.keys),
 	}
 }
 
@@ -191,9 +446,54 @@ func keys(h map[string]interface{}) []string {
 		keys[index] = k
 		index++
 	}
+	sort.Strings(keys)
 	return keys
 }
 
+// structFields returns the exported fields of v as a hash, as well as the fields' names in the order
+// of their declaration. ok will be false if v is neither a struct nor a non-nil pointer to a struct.
+func structFields(v interface{}) (map[string]interface{}, []string, bool) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, nil, false
+	}
+
+	t := value.Type()
+	h := map[string]interface{}{}
+	keys := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		h[f.Name] = value.Field(i).Interface()
+		keys = append(keys, f.Name)
+	}
+
+	return h, keys, true
+}
+
+// runes returns the characters of v as strings. ok will be false if v is not a string.
+func runes(v interface{}) ([]interface{}, bool) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.String {
+		return nil, false
+	}
+
+	rs := []rune(value.String())
+	s := make([]interface{}, len(rs))
+	for i, r := range rs {
+		s[i] = string(r)
+	}
+	return s, true
+}
+
 func toSlice(v interface{}) ([]interface{}, error) {
 	if v == nil {
 		return nil, errors.New("cannot convert nil to slice")
@@ -22,6 +22,8 @@ func TestNewInt(t *testing.T) {
 		is.Equal(s.Even, s.Index%2 == 0)
 		is.Equal(s.Odd, !s.Even)
 		is.Equal(s.HasMore, i < 5)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, 5)
 	}
 
 	is.True(!r.Next()) // no more values
@@ -51,6 +53,8 @@ func TestNewFromTo(t *testing.T) {
 		is.Equal(s.Even, s.Index%2 == 0)
 		is.Equal(s.Odd, !s.Even)
 		is.Equal(s.HasMore, i < 5)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, 5)
 	}
 
 	is.True(!r.Next()) // no more values
@@ -72,6 +76,8 @@ func TestNew_Slice(t *testing.T) {
 		is.Equal(s.Even, s.Index%2 == 0)
 		is.Equal(s.Odd, !s.Even)
 		is.Equal(s.HasMore, i < 5)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, 5)
 	}
 
 	is.True(!r.Next()) // no more values
@@ -85,22 +91,228 @@ func TestNew_SliceEmpty(t *testing.T) {
 	is.True(!r.Next()) // no more values
 }
 
+func TestNewZip(t *testing.T) {
+	is := is.New(t)
+
+	r := NewZip([]int{1, 2, 3}, []string{"a", "b", "c", "d"})
+
+	expected := []Pair{{1, "a"}, {2, "b"}, {3, "c"}}
+
+	for i, p := range expected {
+		is.True(r.Next()) // have value
+		is.Equal(r.Value().(Pair), p)
+
+		s := r.Status()
+		is.Equal(s.Index, i)
+		is.Equal(s.Last, i == 2)
+		is.Equal(s.Total, 3)
+	}
+
+	is.True(!r.Next()) // no more values
+}
+
+func TestNew_String(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"hello", []string{"h", "e", "l", "l", "o"}},
+		{"héllo€", []string{"h", "é", "l", "l", "o", "€"}},
+		{"", []string{}},
+	}
+
+	for _, test := range tests {
+		r := New(test.input)
+
+		for i, ch := range test.expected {
+			is.True(r.Next()) // have value
+			is.Equal(r.Value().(string), ch)
+
+			s := r.Status()
+			is.Equal(s.Index, i)
+			is.Equal(s.Last, i == len(test.expected)-1)
+		}
+
+		is.True(!r.Next()) // no more values
+	}
+}
+
+func TestNewChan(t *testing.T) {
+	is := is.New(t)
+
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+
+	r := NewChan(ch)
+
+	for i := 1; i <= 3; i++ {
+		is.True(r.Next()) // have value
+		is.Equal(r.Value().(int), i)
+
+		s := r.Status()
+		is.Equal(s.Index, i-1)
+		is.Equal(s.First, i == 1)
+		is.Equal(s.Even, s.Index%2 == 0)
+		is.Equal(s.Odd, !s.Even)
+		is.True(!s.Last)
+		is.True(!s.HasMore)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, -1)
+	}
+
+	is.True(!r.Next()) // no more values
+}
+
+func TestNewChan_SendOnly(t *testing.T) {
+	is := is.New(t)
+
+	defer func() {
+		is.True(recover() != nil)
+	}()
+
+	NewChan(make(chan<- int))
+}
+
+func TestNewFunc(t *testing.T) {
+	is := is.New(t)
+
+	count := 0
+	r := NewFunc(func() (interface{}, bool) {
+		if count >= 5 {
+			return nil, false
+		}
+		count++
+		return count, true
+	})
+
+	for i := 1; i <= 5; i++ {
+		is.True(r.Next()) // have value
+		is.Equal(r.Value().(int), i)
+
+		s := r.Status()
+		is.Equal(s.Index, i-1)
+		is.Equal(s.First, i == 1)
+		is.Equal(s.Even, s.Index%2 == 0)
+		is.Equal(s.Odd, !s.Even)
+		is.True(!s.Last)
+		is.True(!s.HasMore)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, -1)
+	}
+
+	is.True(!r.Next()) // no more values
+}
+
+func TestNewFilter(t *testing.T) {
+	is := is.New(t)
+
+	r := NewFilter(New([]int{1, 2, 3, 4, 5, 6, 7}), func(v interface{}) bool {
+		return v.(int)%2 != 0
+	})
+
+	expected := []int{1, 3, 5, 7}
+
+	for i, v := range expected {
+		is.True(r.Next()) // have value
+		is.Equal(r.Value().(int), v)
+
+		s := r.Status()
+		is.Equal(s.Index, i)
+		is.Equal(s.First, i == 0)
+		is.Equal(s.Last, i == 3) // last value of the underlying ranger
+		is.Equal(s.Even, s.Index%2 == 0)
+		is.Equal(s.Odd, !s.Even)
+		is.True(!s.HasMore)
+		is.Equal(s.Number, i+1)
+		is.Equal(s.Total, -1)
+	}
+
+	is.True(!r.Next()) // no more values
+}
+
+func TestNewFilter_Chan(t *testing.T) {
+	is := is.New(t)
+
+	ch := make(chan int)
+	r := NewFilter(NewChan(ch), func(v interface{}) bool {
+		return v.(int)%2 != 0
+	})
+
+	go func() {
+		ch <- 1
+		ch <- 2
+		ch <- 3
+	}()
+
+	// must not block waiting for values beyond the next kept value
+	is.True(r.Next()) // have value
+	is.Equal(r.Value().(int), 1)
+	is.True(r.Next()) // have value
+	is.Equal(r.Value().(int), 3)
+
+	s := r.Status()
+	is.Equal(s.Index, 1)
+	is.True(!s.Last)
+
+	close(ch)
+	is.True(!r.Next()) // no more values
+}
+
+func TestNewFilter_Empty(t *testing.T) {
+	is := is.New(t)
+
+	r := NewFilter(NewInt(0, 10), func(v interface{}) bool {
+		return false
+	})
+
+	is.True(!r.Next()) // no more values
+}
+
+func TestNewMap(t *testing.T) {
+	is := is.New(t)
+
+	r := NewMap(New([]int{1, 2, 3}), func(v interface{}) interface{} {
+		return v.(int) * 2
+	})
+
+	for i := 1; i <= 3; i++ {
+		is.True(r.Next()) // have value
+		is.Equal(r.Value().(int), i*2)
+
+		s := r.Status()
+		is.Equal(s.Index, i-1)
+		is.Equal(s.First, i == 1)
+		is.Equal(s.Last, i == 3)
+		is.Equal(s.Even, s.Index%2 == 0)
+		is.Equal(s.Odd, !s.Even)
+		is.Equal(s.HasMore, i < 3)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, 3)
+	}
+
+	is.True(!r.Next()) // no more values
+}
+
 func TestNew_Hash(t *testing.T) {
 	is := is.New(t)
 
 	r := New(map[string]interface{}{
+		"c": 3,
 		"a": 1,
 		"b": 2,
-		"c": 3,
 	})
 
 	for i := 1; i <= 3; i++ {
 		is.True(r.Next())
 
 		e := r.Value().(HashEntry)
-		is.True(e.Key == "a" || e.Key == "b" || e.Key == "c")
-		v := e.Value.(int)
-		is.True(v == 1 || v == 2 || v == 3)
+		is.Equal(e.Key, string(rune('a'+i-1)))
+		is.Equal(e.Value.(int), i)
 
 		s := r.Status()
 		is.Equal(s.Index, i-1)
@@ -109,6 +321,8 @@ func TestNew_Hash(t *testing.T) {
 		is.Equal(s.Even, s.Index%2 == 0)
 		is.Equal(s.Odd, !s.Even)
 		is.Equal(s.HasMore, i < 3)
+		is.Equal(s.Number, i)
+		is.Equal(s.Total, 3)
 	}
 
 	is.True(!r.Next()) // no more values
@@ -121,3 +335,36 @@ func TestNew_HashEmpty(t *testing.T) {
 
 	is.True(!r.Next()) // no more values
 }
+
+func TestNew_Struct(t *testing.T) {
+	is := is.New(t)
+
+	type foo struct {
+		C      int
+		A      string
+		hidden bool
+		B      bool
+	}
+
+	expected := []HashEntry{
+		{Key: "C", Value: 1},
+		{Key: "A", Value: "x"},
+		{Key: "B", Value: true},
+	}
+
+	for _, v := range []interface{}{foo{C: 1, A: "x", hidden: true, B: true}, &foo{C: 1, A: "x", hidden: true, B: true}} {
+		r := New(v)
+
+		for i, ex := range expected {
+			is.True(r.Next())
+			is.Equal(r.Value().(HashEntry), ex)
+
+			s := r.Status()
+			is.Equal(s.Index, i)
+			is.Equal(s.Last, i == len(expected)-1)
+			is.Equal(s.Total, len(expected))
+		}
+
+		is.True(!r.Next()) // no more values
+	}
+}
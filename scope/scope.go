@@ -1,15 +1,33 @@
 package scope
 
+import (
+	"sort"
+	"sync"
+)
+
 // Scope is a map of values indexed by identifiers.
 //
 // A scope may have a parent scope. If the current scope does not store a value for a
 // specific identifier, the parent scopes will be considered (recursively.)
+//
+// A Scope is not safe for concurrent use by multiple goroutines, unless it has been created using NewSynced.
 type Scope struct {
 	// Parent is the parent scope of this scope.
 	Parent *Scope
 
 	values map[string]interface{}
 	locked bool
+	mu     *sync.RWMutex
+}
+
+// NewSynced returns a new scope with parent scope p (which may be nil) that is safe for concurrent use by
+// multiple goroutines. Note that the parent scopes are only safe for concurrent use if they have been
+// created using NewSynced as well.
+func NewSynced(p *Scope) *Scope {
+	return &Scope{
+		Parent: p,
+		mu:     &sync.RWMutex{},
+	}
 }
 
 // Set stores the value v identified by name in the scope.
@@ -18,22 +36,16 @@ type Scope struct {
 // identifier, the value is stored (overwritten) in the parent scope instead. Parent scopes are considered
 // recursively until there is no parent scope.
 //
-// If the scope where the value should be stored is locked, nothing will happen.
+// If the scope where the value should be stored is locked, nothing will happen (see IsReadOnly.)
 func (s *Scope) Set(name string, v interface{}) {
-	ps := s.Parent
-	parentHasValue := false
-	for ps != nil {
-		if parentHasValue = hasValueSelf(ps, name); parentHasValue {
-			break
+	for ps := s.Parent; ps != nil; ps = ps.Parent {
+		if setIfHasValueSelf(ps, name, v) {
+			return
 		}
-
-		ps = ps.Parent
 	}
 
-	if parentHasValue {
-		ps.values[name] = v
-		return
-	}
+	s.lockWrite()
+	defer s.unlockWrite()
 
 	if s.locked {
 		return
@@ -43,6 +55,21 @@ func (s *Scope) Set(name string, v interface{}) {
 	s.values[name] = v
 }
 
+// IsReadOnly returns whether the value identified by name is stored in a locked scope, that is, whether
+// Set would not be able to overwrite it. It returns false if neither the scope nor any of its parent scopes
+// store a value identified by name.
+func (s *Scope) IsReadOnly(name string) bool {
+	for {
+		if found, locked := lockedSelf(s, name); found {
+			return locked
+		}
+
+		if s = s.Parent; s == nil {
+			return false
+		}
+	}
+}
+
 // HasValue returns whether the scope or any of its parent scopes store a value identified by name.
 func (s *Scope) HasValue(name string) bool {
 	for {
@@ -60,10 +87,8 @@ func (s *Scope) HasValue(name string) bool {
 // If there is a value, ok will be true, otherwise it will be false.
 func (s *Scope) Value(name string) (interface{}, bool) {
 	for {
-		if s.values != nil {
-			if v, ok := s.values[name]; ok {
-				return v, true
-			}
+		if v, ok := valueSelf(s, name); ok {
+			return v, true
 		}
 
 		if s = s.Parent; s == nil {
@@ -72,13 +97,123 @@ func (s *Scope) Value(name string) (interface{}, bool) {
 	}
 }
 
+// Delete removes the value identified by name from the scope that stores it, which may be the scope
+// itself or any of its parent scopes (recursively.) It returns whether a value has been removed.
+//
+// If the scope that stores the value is locked, nothing will happen.
+func (s *Scope) Delete(name string) bool {
+	for {
+		if deleted, found := deleteSelf(s, name); found {
+			return deleted
+		}
+
+		if s = s.Parent; s == nil {
+			return false
+		}
+	}
+}
+
+// Keys returns the identifiers of all values stored in this scope or any of its parent scopes, in sorted order.
+// Each identifier is only returned once, even if it is stored in multiple scopes.
+func (s *Scope) Keys() []string {
+	seen := map[string]struct{}{}
+	keys := []string{}
+
+	for {
+		for _, k := range s.OwnKeys() {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+
+			seen[k] = struct{}{}
+			keys = append(keys, k)
+		}
+
+		if s = s.Parent; s == nil {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// OwnKeys returns the identifiers of all values stored in this scope, not including any parent scopes,
+// in sorted order.
+func (s *Scope) OwnKeys() []string {
+	s.lockRead()
+	defer s.unlockRead()
+
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Clone returns a copy of this scope. Parent scopes are cloned recursively, so that modifications to the
+// copy or any of its parent scopes do not affect the original scopes, and vice versa. The values themselves
+// are not copied. The copy will be locked if the original scope is locked, and it will be safe for concurrent
+// use if the original scope is (see NewSynced.)
+func (s *Scope) Clone() *Scope {
+	s.lockRead()
+	defer s.unlockRead()
+
+	c := Scope{
+		locked: s.locked,
+	}
+
+	if s.mu != nil {
+		c.mu = &sync.RWMutex{}
+	}
+
+	if s.Parent != nil {
+		c.Parent = s.Parent.Clone()
+	}
+
+	if s.values != nil {
+		c.values = make(map[string]interface{}, len(s.values))
+		for k, v := range s.values {
+			c.values[k] = v
+		}
+	}
+
+	return &c
+}
+
+// Merge stores all values of the scope o in this scope, not including any of o's parent scopes.
+// The values are stored using Set, so the same rules apply regarding parent scopes and locked scopes.
+// Values already stored for the same identifiers are overwritten.
+func (s *Scope) Merge(o *Scope) {
+	o.lockRead()
+	values := make(map[string]interface{}, len(o.values))
+	for k, v := range o.values {
+		values[k] = v
+	}
+	o.unlockRead()
+
+	for k, v := range values {
+		s.Set(k, v)
+	}
+}
+
 // Lock prevents this scope from further modification. Parent scopes (if any) will not be locked.
 func (s *Scope) Lock() {
+	s.lockWrite()
+	defer s.unlockWrite()
+
 	s.locked = true
 }
 
 // ClearSelf removes all values associated with this scope, not including any parent scopes.
 func (s *Scope) ClearSelf() {
+	s.lockWrite()
+	defer s.unlockWrite()
+
 	if s.values != nil {
 		for k := range s.values {
 			delete(s.values, k)
@@ -92,10 +227,91 @@ func (s *Scope) init() {
 	}
 }
 
+func (s *Scope) lockRead() {
+	if s.mu != nil {
+		s.mu.RLock()
+	}
+}
+
+func (s *Scope) unlockRead() {
+	if s.mu != nil {
+		s.mu.RUnlock()
+	}
+}
+
+func (s *Scope) lockWrite() {
+	if s.mu != nil {
+		s.mu.Lock()
+	}
+}
+
+func (s *Scope) unlockWrite() {
+	if s.mu != nil {
+		s.mu.Unlock()
+	}
+}
+
 func hasValueSelf(s *Scope, name string) bool {
+	_, ok := valueSelf(s, name)
+	return ok
+}
+
+func valueSelf(s *Scope, name string) (interface{}, bool) {
+	s.lockRead()
+	defer s.unlockRead()
+
 	if s.values == nil {
+		return nil, false
+	}
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// setIfHasValueSelf stores the value v identified by name in s if s already stores a value for that identifier,
+// and if s is not locked. It returns whether s stores a value for that identifier.
+func setIfHasValueSelf(s *Scope, name string, v interface{}) bool {
+	s.lockWrite()
+	defer s.unlockWrite()
+
+	if s.values == nil {
+		return false
+	}
+	if _, ok := s.values[name]; !ok {
 		return false
 	}
+	if !s.locked {
+		s.values[name] = v
+	}
+	return true
+}
+
+// lockedSelf returns whether s stores a value identified by name, and whether s is locked.
+func lockedSelf(s *Scope, name string) (found bool, locked bool) {
+	s.lockRead()
+	defer s.unlockRead()
+
+	if s.values == nil {
+		return false, false
+	}
 	_, ok := s.values[name]
-	return ok
+	return ok, ok && s.locked
+}
+
+// deleteSelf removes the value identified by name from s. found will be true if s stores a value for that
+// identifier, and deleted will be true if it has actually been removed (that is, s is not locked.)
+func deleteSelf(s *Scope, name string) (deleted bool, found bool) {
+	s.lockWrite()
+	defer s.unlockWrite()
+
+	if s.values == nil {
+		return false, false
+	}
+	if _, ok := s.values[name]; !ok {
+		return false, false
+	}
+	if s.locked {
+		return false, true
+	}
+	delete(s.values, name)
+	return true, true
 }
@@ -1,6 +1,7 @@
 package scope
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/matryer/is"
@@ -54,6 +55,28 @@ func TestScope_Lock(t *testing.T) {
 	testIntValue(&s, "x", 5, is) // no change
 }
 
+func TestScope_Lock_Parent(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 5)
+	a.Lock()
+
+	b := Scope{
+		Parent: &a,
+	}
+
+	is.True(b.IsReadOnly("x"))
+	is.True(!b.IsReadOnly("y")) // no value
+
+	b.Set("x", 42)
+	b.Set("y", 42)
+
+	testIntValue(&b, "x", 5, is) // no change
+	testIntValue(&b, "y", 42, is)
+	is.True(!b.IsReadOnly("y"))
+}
+
 func TestScope_ClearSelf(t *testing.T) {
 	is := is.New(t)
 
@@ -65,6 +88,185 @@ func TestScope_ClearSelf(t *testing.T) {
 	testNoValue(&s, "x", is) // removed
 }
 
+func TestScope_Delete(t *testing.T) {
+	is := is.New(t)
+
+	s := Scope{}
+	s.Set("x", 5)
+
+	is.True(s.Delete("x"))
+	testNoValue(&s, "x", is) // removed
+
+	is.True(!s.Delete("x")) // nothing left to remove
+}
+
+func TestScope_Delete_Parent(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 5)
+
+	b := Scope{
+		Parent: &a,
+	}
+	b.Set("y", 42)
+
+	is.True(b.Delete("x"))
+	testNoValue(&a, "x", is) // removed from parent
+	testNoValue(&b, "x", is)
+	testIntValue(&b, "y", 42, is) // untouched
+}
+
+func TestScope_Delete_Lock(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 5)
+	a.Lock()
+
+	b := Scope{
+		Parent: &a,
+	}
+
+	is.True(!b.Delete("x"))
+	testIntValue(&b, "x", 5, is) // no change
+
+	is.True(!a.Delete("x"))
+	testIntValue(&a, "x", 5, is) // no change
+}
+
+func TestScope_Keys(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 1)
+	a.Set("y", 2)
+
+	b := Scope{
+		Parent: &a,
+	}
+
+	c := Scope{
+		Parent: &b,
+	}
+	c.Set("z", 3)
+	c.values["x"] = 4 // shadow x in parent
+
+	is.Equal(c.Keys(), []string{"x", "y", "z"})
+	is.Equal(c.OwnKeys(), []string{"x", "z"})
+
+	is.Equal(b.Keys(), []string{"x", "y"})
+	is.Equal(b.OwnKeys(), []string{})
+}
+
+func TestScope_Clone(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 1)
+
+	b := Scope{
+		Parent: &a,
+	}
+	b.Set("y", 2)
+
+	c := b.Clone()
+
+	b.Set("x", 10)        // stored in a
+	c.Set("y", 20)        // stored in clone of b
+	c.Set("z", 30)        // stored in clone of b
+	a.Set("w", 40)        // stored in a only
+	c.Parent.Set("v", 50) // stored in clone of a only
+
+	testIntValue(&b, "x", 10, is)
+	testIntValue(&b, "y", 2, is)
+	testNoValue(&b, "z", is)
+	testNoValue(&b, "v", is)
+
+	testIntValue(c, "x", 1, is)
+	testIntValue(c, "y", 20, is)
+	testIntValue(c, "z", 30, is)
+	testIntValue(c, "v", 50, is)
+	testNoValue(c, "w", is)
+}
+
+func TestScope_Merge(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 1)
+	a.Set("y", 2)
+
+	op := Scope{}
+	op.Set("p", 100)
+
+	o := Scope{
+		Parent: &op,
+	}
+	o.Set("y", 20)
+	o.Set("z", 30)
+
+	a.Merge(&o)
+
+	testIntValue(&a, "x", 1, is)
+	testIntValue(&a, "y", 20, is) // overwritten
+	testIntValue(&a, "z", 30, is)
+	testNoValue(&a, "p", is) // not merged from parent
+	testIntValue(&o, "y", 20, is)
+}
+
+func TestScope_Merge_Lock(t *testing.T) {
+	is := is.New(t)
+
+	a := Scope{}
+	a.Set("x", 1)
+	a.Lock()
+
+	o := Scope{}
+	o.Set("x", 10)
+	o.Set("y", 20)
+
+	a.Merge(&o)
+
+	testIntValue(&a, "x", 1, is) // no change
+	testNoValue(&a, "y", is)
+}
+
+func TestNewSynced(t *testing.T) {
+	is := is.New(t)
+
+	parent := NewSynced(nil)
+	parent.Set("x", 0)
+
+	s := NewSynced(parent)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				s.Set("x", i) // stored in parent
+				s.Set("y", j) // stored in s
+				_, _ = s.Value("x")
+				_ = s.HasValue("y")
+				_ = s.Keys()
+
+				if j%10 == 0 {
+					s.ClearSelf()
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	is.True(s.HasValue("x"))
+}
+
 func testIntValue(s *Scope, name string, v int, is *is.I) { //nolint:unparam
 	is.True(s.HasValue(name))
 	actual, ok := s.Value(name)
@@ -0,0 +1,67 @@
+package template
+
+import (
+	"errors"
+	"strings"
+)
+
+// A TemplateError is returned when rendering a template fails. If the error occurred while rendering another
+// template called by the template (see WithTemplateFuncName), Err is the TemplateError of that other template,
+// so that the chain of templates leading to the error can be reconstructed (see Chain.)
+type TemplateError struct {
+	// Name is the name of the template.
+	Name string
+
+	// Err is the error that occurred while rendering the template.
+	Err error
+}
+
+// Error returns a message that includes the chain of templates leading to the error, separated by arrows,
+// such as "error rendering template a → b: <error>". If Err wraps the TemplateError of another template with
+// additional context, such as fmt.Errorf("including b: %w", err), that context is included after the name of
+// the template, such as "error rendering template a (including b) → b: <error>".
+func (e *TemplateError) Error() string {
+	hops := []string{}
+	cause := error(e)
+
+	var te *TemplateError
+	for errors.As(cause, &te) {
+		cause = te.Err
+		hops = append(hops, te.Name+wrapContext(cause))
+	}
+
+	return "error rendering template " + strings.Join(hops, " → ") + ": " + cause.Error()
+}
+
+// wrapContext returns the context that err adds when wrapping the TemplateError of another template,
+// such as " (including b)" for fmt.Errorf("including b: %w", err), or "" if there is none.
+func wrapContext(err error) string {
+	var te *TemplateError
+	if !errors.As(err, &te) || err == error(te) {
+		return ""
+	}
+
+	msg := err.Error()
+	prefix := strings.TrimSuffix(msg, te.Error())
+	if prefix == msg || prefix == "" {
+		return ""
+	}
+
+	return " (" + strings.TrimSuffix(prefix, ": ") + ")"
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Chain returns the names of the templates leading to the error, starting with the outermost template.
+func (e *TemplateError) Chain() []string {
+	names := []string{}
+
+	var te *TemplateError
+	for err := error(e); errors.As(err, &te); err = te.Err {
+		names = append(names, te.Name)
+	}
+
+	return names
+}
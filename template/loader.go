@@ -0,0 +1,60 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FSLoader returns a loader that loads templates from the file system fsys, such as an embed.FS or the result
+// of os.DirFS. The name of a template is resolved to the path prefix/name+ext, where prefix and ext may be empty.
+// Names and prefix must use forward slashes as separators, and must not contain "." or ".." elements
+// (see fs.ValidPath.) If a template cannot be opened, the loader returns an error wrapping the error returned
+// by fsys, for example fs.ErrNotExist.
+func FSLoader(fsys fs.FS, prefix string, ext string) Loader {
+	return LoaderFunc(func(name string) (io.ReadCloser, error) {
+		p := name + ext
+		if !fs.ValidPath(p) {
+			// prevent escaping from prefix using ".." elements
+			return nil, fmt.Errorf("cannot load template %s: %w", name, &fs.PathError{Op: "open", Path: p, Err: fs.ErrInvalid})
+		}
+
+		if prefix != "" {
+			p = path.Join(prefix, p)
+		}
+
+		f, err := fsys.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load template %s: %w", name, err)
+		}
+
+		return f, nil
+	})
+}
+
+// DirLoader returns a loader that loads templates from the directory baseDir. The name of a template is resolved
+// to the file name+ext in baseDir. Names may use forward slashes as separators to load templates from nested
+// directories. Names that would resolve to a file outside of baseDir, such as by using ".." elements, are rejected
+// with an error wrapping fs.ErrInvalid. If a template cannot be opened, the loader returns an error wrapping the
+// error returned by os.Open, for example fs.ErrNotExist.
+func DirLoader(baseDir string, ext string) Loader {
+	return LoaderFunc(func(name string) (io.ReadCloser, error) {
+		p := filepath.Join(baseDir, filepath.FromSlash(name+ext))
+
+		rel, err := filepath.Rel(baseDir, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("cannot load template %s: %w", name, &fs.PathError{Op: "open", Path: p, Err: fs.ErrInvalid})
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load template %s: %w", name, err)
+		}
+
+		return f, nil
+	})
+}
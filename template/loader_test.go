@@ -0,0 +1,100 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/matryer/is"
+)
+
+func TestFSLoader(t *testing.T) {
+	is := is.New(t)
+
+	fsys := fstest.MapFS{
+		"templates/index.html":           {Data: []byte(`index <% t("partials/header", nil) %>`)},
+		"templates/partials/header.html": {Data: []byte(`header`)},
+	}
+
+	r := NewRenderer(FSLoader(fsys, "templates", ".html"))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "index", nil)
+	is.NoErr(err)
+	is.Equal(buf.String(), "index header")
+}
+
+func TestFSLoader_NoPrefix(t *testing.T) {
+	is := is.New(t)
+
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte(`index`)},
+	}
+
+	r := NewRenderer(FSLoader(fsys, "", ""))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "index.html", nil)
+	is.NoErr(err)
+	is.Equal(buf.String(), "index")
+}
+
+func TestFSLoader_NotExist(t *testing.T) {
+	is := is.New(t)
+
+	r := NewRenderer(FSLoader(fstest.MapFS{}, "templates", ".html"))
+
+	err := r.Render(context.Background(), &bytes.Buffer{}, "missing", nil)
+	is.True(errors.Is(err, fs.ErrNotExist))
+	is.Equal(err.Error(), "cannot load template missing: open templates/missing.html: file does not exist")
+}
+
+func TestFSLoader_InvalidName(t *testing.T) {
+	is := is.New(t)
+
+	fsys := fstest.MapFS{
+		"secret.html": {Data: []byte(`secret`)},
+	}
+
+	r := NewRenderer(FSLoader(fsys, "templates", ".html"))
+
+	err := r.Render(context.Background(), &bytes.Buffer{}, "../secret", nil)
+	is.True(errors.Is(err, fs.ErrInvalid))
+}
+
+func TestDirLoader(t *testing.T) {
+	is := is.New(t)
+
+	dir := t.TempDir()
+	baseDir := filepath.Join(dir, "templates")
+
+	is.NoErr(os.MkdirAll(filepath.Join(baseDir, "partials"), 0o700))
+	is.NoErr(os.WriteFile(filepath.Join(baseDir, "index.html"), []byte(`index <% t("partials/header", nil) %>`), 0o600))
+	is.NoErr(os.WriteFile(filepath.Join(baseDir, "partials", "header.html"), []byte(`header`), 0o600))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "secret.html"), []byte(`secret`), 0o600))
+
+	r := NewRenderer(DirLoader(baseDir, ".html"))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "index", nil)
+	is.NoErr(err)
+	is.Equal(buf.String(), "index header")
+
+	err = r.Render(context.Background(), &bytes.Buffer{}, "missing", nil)
+	is.True(errors.Is(err, fs.ErrNotExist))
+
+	for _, name := range []string{"../secret", "partials/../../secret"} {
+		err = r.Render(context.Background(), &bytes.Buffer{}, name, nil)
+		is.True(errors.Is(err, fs.ErrInvalid)) // traversal rejected
+	}
+
+	buf.Reset()
+	err = r.Render(context.Background(), &buf, "partials/../partials/header", nil)
+	is.NoErr(err) // stays inside base directory
+	is.Equal(buf.String(), "header")
+}
@@ -3,10 +3,15 @@ package template
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"path"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/evaluator"
@@ -17,9 +22,21 @@ import (
 
 // Renderer parses templates, evaluates their code, and writes out the output.
 type Renderer struct {
-	loader           Loader
-	scopeData        map[string]interface{}
-	templateFuncName string
+	loader            Loader
+	scopeData         map[string]interface{}
+	templateFuncName  string
+	escapersByExt     map[string]Escaper
+	maxCallDepth      int
+	loopTimeout       time.Duration
+	literalTrim       string
+	strictVars        bool
+	autoEscapeHTML    bool
+	streaming         bool
+	unsafePlaceholder string
+	unsafeError       bool
+	caching           bool
+	cache             map[string]*CompiledTemplate
+	cacheMu           sync.Mutex
 }
 
 // A Loader loads a template with a specific name and returns it as a reader.
@@ -31,11 +48,51 @@ type Loader interface {
 // If f is a function with the appropriate signature, LoaderFunc(f) is a loader that calls f.
 type LoaderFunc func(name string) (io.ReadCloser, error)
 
+// defaultUnsafePlaceholder is output instead of values that are not SafeStrings, unless configured otherwise.
+const defaultUnsafePlaceholder = "!UNSAFE!"
+
+// ErrUnsafeOutput is returned when a template tries to output a value that is not a SafeString, and the renderer
+// has been configured to stop with an error in that case (see WithUnsafeError.)
+var ErrUnsafeOutput = errors.New("output of value that is not a SafeString")
+
+// An Escaper converts a value that is not a SafeString to a SafeString for output, escaping it as necessary.
+type Escaper func(v interface{}) SafeString
+
+// escapeFunc converts a value that is not a SafeString to a SafeString for output, or returns an error if the
+// value cannot be output.
+type escapeFunc func(v interface{}) (SafeString, error)
+
 // Opt is the type of a function that configures r.
 type Opt func(*Renderer)
 
+// A SourceMap maps ranges of rendered output to the positions in a template that produced them.
+// Its mappings are ordered by their position in the output.
+type SourceMap []SourceMapping
+
+// A SourceMapping maps the output bytes from Start (inclusive) to End (exclusive) to the template position
+// (line and column) of the top-level statement that produced them. Output produced by nested statements, such
+// as in the block of an if or for expression, or by rendering other templates, is mapped to the enclosing top-level
+// statement.
+type SourceMapping struct {
+	Start int
+	End   int
+	Line  int
+	Col   int
+}
+
+// A CompiledTemplate is a template that has been loaded and parsed by a renderer, and that can be executed
+// multiple times, with different data, without parsing it again. A CompiledTemplate may be executed
+// concurrently.
+type CompiledTemplate struct {
+	r    *Renderer
+	name string
+	prog *ast.Program
+}
+
 // SafeString encapsulates a regular string to mark it as safe for output.
-// If template code tries to output a regular string, it will be rendered only as "!UNSAFE!".
+// If template code tries to output a regular string, it will be rendered only as "!UNSAFE!",
+// unless an Escaper has been configured (see WithEscaperByExt), or the renderer has been configured otherwise
+// (see WithUnsafePlaceholder and WithUnsafeError.)
 // Instead, regular strings must be wrapped in SafeString to render them as expected.
 // Before wrapping in SafeString, strings should be HTML-escaped etc., depending on the output's language.
 type SafeString string
@@ -43,9 +100,11 @@ type SafeString string
 // NewRenderer returns a new renderer, configured with opts, that loads templates via load.
 func NewRenderer(loader Loader, opts ...Opt) *Renderer {
 	r := &Renderer{
-		loader:           loader,
-		templateFuncName: "t",
-		scopeData:        map[string]interface{}{},
+		loader:            loader,
+		templateFuncName:  "t",
+		scopeData:         map[string]interface{}{},
+		strictVars:        true,
+		unsafePlaceholder: defaultUnsafePlaceholder,
 	}
 
 	for _, opt := range opts {
@@ -73,6 +132,14 @@ func WithScopeDataMap(data map[string]interface{}) Opt {
 	}
 }
 
+// WithFuncMap configures a renderer to provide the functions in funcs to all templates being rendered, using the
+// keys of funcs as their names. This is a convenient way to register a whole set of functions at once, such as
+// helpers.DefaultFuncs(). WithFuncMap may be used multiple times, also in combination with WithScopeData and
+// WithScopeDataMap.
+func WithFuncMap(funcs map[string]interface{}) Opt {
+	return WithScopeDataMap(funcs)
+}
+
 // WithTemplateFuncName configures a renderer to use n as the name of the function that may be called in
 // templates to render other templates. The default name of this function is "t".
 //
@@ -82,23 +149,248 @@ func WithScopeDataMap(data map[string]interface{}) Opt {
 //
 // where name is the name of the template to render.
 //
-// The data map is in turn provided to the new renderer using WithScopeData.
+// The other template is rendered like a template passed to Render, using data as its data. It can therefore access
+// data as well as the renderer's scope data (see WithScopeData), but not the data of the calling template, nor any
+// variables declared by the calling template using let statements. Likewise, variables declared by the other
+// template are not visible to the calling template. To make values of the calling template available to the other
+// template, they must be passed explicitly using data.
 func WithTemplateFuncName(n string) Opt {
 	return func(r *Renderer) {
 		r.templateFuncName = n
 	}
 }
 
+// WithEscaperByExt configures a renderer to use escapers to output values that are not SafeStrings,
+// depending on the extension of the name of the template being rendered. The keys of escapers are
+// extensions including the leading dot, such as ".html". Values output by templates with other extensions
+// are rendered only as "!UNSAFE!" (see SafeString.)
+//
+// WithEscaperByExt may be used multiple times, adding to the existing escapers.
+func WithEscaperByExt(escapers map[string]Escaper) Opt {
+	return func(r *Renderer) {
+		if r.escapersByExt == nil {
+			r.escapersByExt = map[string]Escaper{}
+		}
+		for ext, e := range escapers {
+			r.escapersByExt[ext] = e
+		}
+	}
+}
+
+// WithAutoEscapeHTML configures a renderer to HTML-escape values that are not SafeStrings for output, instead of
+// rendering them only as "!UNSAFE!" (see SafeString.) SafeStrings, including literal output, are still output
+// without escaping. Escapers configured for the extension of a template's name take precedence (see WithEscaperByExt.)
+func WithAutoEscapeHTML() Opt {
+	return func(r *Renderer) {
+		r.autoEscapeHTML = true
+	}
+}
+
+// WithUnsafePlaceholder configures a renderer to output p instead of values that are not SafeStrings, if no escaper
+// is used for them (see WithEscaperByExt and WithAutoEscapeHTML.) The default placeholder is "!UNSAFE!".
+func WithUnsafePlaceholder(p string) Opt {
+	return func(r *Renderer) {
+		r.unsafePlaceholder = p
+	}
+}
+
+// WithUnsafeError configures a renderer to stop with an error wrapping ErrUnsafeOutput if template code tries to
+// output a value that is not a SafeString, and no escaper is used for it (see WithEscaperByExt and
+// WithAutoEscapeHTML.) This overrides WithUnsafePlaceholder. The default is to output a placeholder instead.
+func WithUnsafeError() Opt {
+	return func(r *Renderer) {
+		r.unsafeError = true
+	}
+}
+
+// WithMaxCallDepth configures a renderer to stop with an error if the depth of nested method or function calls
+// would exceed n, including calls to the function that renders other templates (see WithTemplateFuncName.)
+// This prevents infinite recursion when templates include themselves. The default is to not limit the call depth.
+func WithMaxCallDepth(n int) Opt {
+	return func(r *Renderer) {
+		r.maxCallDepth = n
+	}
+}
+
+// WithLoopTimeout configures a renderer to stop with an error if a single for loop in a template runs for longer
+// than d. The default is to not limit the time of loops.
+func WithLoopTimeout(d time.Duration) Opt {
+	return func(r *Renderer) {
+		r.loopTimeout = d
+	}
+}
+
+// WithLiteralTrim configures a renderer to remove all leading and trailing characters contained in cutset from
+// literal output, that is, from template text outside of code blocks. The output of code is not affected.
+// The default is to output literal text as is.
+func WithLiteralTrim(cutset string) Opt {
+	return func(r *Renderer) {
+		r.literalTrim = cutset
+	}
+}
+
+// WithStrictVars configures whether a renderer stops with an error when template code uses an identifier that
+// cannot be found, or a key that cannot be found in a map. If strict is false, the identifier or map entry
+// evaluates to nil instead, which is output as an empty string. The default is to stop with an error.
+func WithStrictVars(strict bool) Opt {
+	return func(r *Renderer) {
+		r.strictVars = strict
+	}
+}
+
+// WithStreaming configures a renderer to write the output of each top-level statement of a template to the writer
+// as soon as the statement has been evaluated, instead of writing all output after the whole template has been
+// evaluated. If writing fails, for example because the client has disconnected, or if the context is canceled,
+// rendering stops immediately with an error, without evaluating the remaining statements. Output produced by
+// nested statements, such as in the block of an if or for expression, or by rendering other templates, is written
+// together with its enclosing top-level statement. The default is to not stream output.
+func WithStreaming() Opt {
+	return func(r *Renderer) {
+		r.streaming = true
+	}
+}
+
+// WithCache configures a renderer to cache compiled templates (see Compile) by name, so that subsequent renders
+// of the same template skip loading and parsing it. The cache may be cleared using ClearCache, for example when
+// templates have been modified. The default is to not cache templates.
+func WithCache() Opt {
+	return func(r *Renderer) {
+		r.caching = true
+	}
+}
+
+// ClearCache removes all parsed templates from the renderer's cache (see WithCache.)
+func (r *Renderer) ClearCache() {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+
+	for name := range r.cache {
+		delete(r.cache, name)
+	}
+}
+
 // Render loads a template with a specific name, evaluates it (optionally passing additional data), and writes the output to w.
+// The data is read-only for the template, as is the renderer's scope data (see WithScopeData.)
 //
 // If the template calls the renderer's function to render other templates (see WithTemplateFuncName), the data map passed to
-// Render will not be passed to those templates.
+// Render will not be passed to those templates. Instead, they are rendered using the data passed to that function.
 //
 // Literal output is wrapped in SafeString without further escaping.
 //
 // The context is passed to an internal evaluator.ArgumentResolver and can therefore be resolved automatically
 // as an argument to method or function calls in template code.
 func (r *Renderer) Render(ctx context.Context, w io.Writer, name string, data map[string]interface{}) error {
+	t, err := r.Compile(name)
+	if err != nil {
+		return err
+	}
+	return t.Execute(ctx, w, data)
+}
+
+// RenderToString is the same as Render, but returns the output as a string.
+func (r *Renderer) RenderToString(ctx context.Context, name string, data map[string]interface{}) (string, error) {
+	buf := strings.Builder{}
+	if err := r.Render(ctx, &buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderProgram evaluates the already parsed template prog (optionally passing additional data), and writes the
+// output to w. This allows rendering templates that have been built or transformed programmatically, bypassing
+// the renderer's loader. Since prog has no name, no escaper is used (see WithEscaperByExt.) See Render for details.
+func (r *Renderer) RenderProgram(ctx context.Context, w io.Writer, prog *ast.Program, data map[string]interface{}) error {
+	return r.execute(ctx, w, "", prog, data, 0, nil)
+}
+
+// Compile loads and parses a template with a specific name, and returns it as a compiled template that can be
+// executed later. Render is equivalent to calling Compile, followed by calling Execute on the compiled template.
+// If caching is enabled (see WithCache), the compiled template is also stored in the cache.
+func (r *Renderer) Compile(name string) (*CompiledTemplate, error) {
+	if r.caching {
+		r.cacheMu.Lock()
+		t, ok := r.cache[name]
+		r.cacheMu.Unlock()
+
+		if ok {
+			return t, nil
+		}
+	}
+
+	prog, err := r.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &CompiledTemplate{
+		r:    r,
+		name: name,
+		prog: prog,
+	}
+
+	if r.caching {
+		r.cacheMu.Lock()
+		if r.cache == nil {
+			r.cache = map[string]*CompiledTemplate{}
+		}
+		r.cache[name] = t
+		r.cacheMu.Unlock()
+	}
+
+	return t, nil
+}
+
+// RenderWithSourceMap is the same as Render, but additionally returns a source map that maps the output
+// written to w back to the positions in the template that produced it.
+func (r *Renderer) RenderWithSourceMap(ctx context.Context, w io.Writer, name string, data map[string]interface{}) (SourceMap, error) {
+	sm := SourceMap{}
+	if err := r.renderDepth(ctx, w, name, data, 0, &sm); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// RenderEach renders a template with a specific name once for each of items, passing the item as additional data,
+// and writes the concatenated output to w. See Render for details.
+//
+// The template is loaded and parsed only once, so all items are rendered using the same version of the template.
+// Rendering stops with the first item that produces an error.
+func (r *Renderer) RenderEach(ctx context.Context, w io.Writer, name string, items []map[string]interface{}) error {
+	t, err := r.Compile(name)
+	if err != nil {
+		return err
+	}
+
+	for i, data := range items {
+		if err := t.Execute(ctx, w, data); err != nil {
+			return fmt.Errorf("error rendering item %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// renderDepth is the same as Render, but passes the call depth d on to the evaluator, so that the depth of
+// nested calls to the template function can be limited (see WithMaxCallDepth.) If sm is not nil, a source map
+// of the output is recorded into it.
+func (r *Renderer) renderDepth(ctx context.Context, w io.Writer, name string, data map[string]interface{}, d evaluator.CallDepth,
+	sm *SourceMap) error {
+	prog, err := r.program(name)
+	if err != nil {
+		return err
+	}
+
+	return r.execute(ctx, w, name, prog, data, d, sm)
+}
+
+// execute evaluates the parsed template prog with a specific name, and writes the output to w. See renderDepth
+// for details.
+func (r *Renderer) execute(ctx context.Context, w io.Writer, name string, prog *ast.Program, data map[string]interface{},
+	d evaluator.CallDepth, sm *SourceMap) error {
+	prog, blocks, err := r.resolveExtends(name, prog)
+	if err != nil {
+		return err
+	}
+
 	userScope := scope.Scope{}
 
 	if r.scopeData != nil {
@@ -117,9 +409,9 @@ func (r *Renderer) Render(ctx context.Context, w io.Writer, name string, data ma
 		Parent: &userScope,
 	}
 
-	renderTemplateFunc := func(name string, data map[string]interface{}, ctx context.Context) (SafeString, error) {
+	renderTemplateFunc := func(name string, data map[string]interface{}, ctx context.Context, d evaluator.CallDepth) (SafeString, error) {
 		buf := bytes.Buffer{}
-		if err := r.Render(ctx, &buf, name, data); err != nil {
+		if err := r.renderDepth(ctx, &buf, name, data, d, nil); err != nil {
 			return "", err
 		}
 		return SafeString(buf.String()), nil
@@ -129,33 +421,247 @@ func (r *Renderer) Render(ctx context.Context, w io.Writer, name string, data ma
 
 	rendererScope.Lock()
 
-	rd, err := r.loader.Load(name)
-	if err != nil {
-		return err
-	}
-	defer rd.Close()
+	escaper := r.escapeFunc(name)
 
-	err = Render(rd, w, data, &rendererScope,
+	evaluatorOpts := []evaluator.Opt{
 		evaluator.WithLiteralStringer(evaluator.LiteralStringerFunc(func(s string) (interface{}, error) {
+			if r.literalTrim != "" {
+				s = strings.Trim(s, r.literalTrim)
+			}
 			return SafeString(s), nil
 		})),
 		evaluator.WithArgumentResolver(evaluator.ArgumentResolverFunc(func(t reflect.Type) (interface{}, error) {
 			return resolveContext(t, ctx)
 		})),
-	)
+		evaluator.WithCallDepth(d),
+		evaluator.WithMaxCallDepth(r.maxCallDepth),
+		evaluator.WithLoopTimeout(r.loopTimeout),
+		evaluator.WithBlocks(blocks),
+		evaluator.WithStrictVars(r.strictVars),
+	}
+
+	if r.streaming {
+		err = streamParsed(ctx, prog, w, data, &rendererScope, escaper, sm, evaluatorOpts...)
+	} else {
+		err = renderParsed(prog, w, data, &rendererScope, escaper, sm, evaluatorOpts...)
+	}
 	if err != nil {
-		return fmt.Errorf("error rendering template %s: %w", name, err)
+		if name == "" {
+			return fmt.Errorf("error rendering program: %w", err)
+		}
+		return &TemplateError{Name: name, Err: err}
 	}
 
 	return nil
 }
 
+// escapeFunc returns the function used to output values that are not SafeStrings in the template with a specific
+// name.
+func (r *Renderer) escapeFunc(name string) escapeFunc {
+	if e := r.escapersByExt[path.Ext(name)]; e != nil {
+		return escapeWith(e)
+	}
+
+	if r.autoEscapeHTML {
+		return escapeWith(escapeHTML)
+	}
+
+	if r.unsafeError {
+		return func(v interface{}) (SafeString, error) {
+			return "", fmt.Errorf("%w: %T", ErrUnsafeOutput, v)
+		}
+	}
+
+	return escapeWithPlaceholder(r.unsafePlaceholder)
+}
+
+// Execute evaluates the compiled template (optionally passing additional data), and writes the output to w.
+// See Renderer.Render for details.
+func (t *CompiledTemplate) Execute(ctx context.Context, w io.Writer, data map[string]interface{}) error {
+	return t.r.execute(ctx, w, t.name, t.prog, data, 0, nil)
+}
+
+// resolveExtends follows the chain of templates extended by the template prog with a specific name (see
+// ast.ExtendsStatement.) It returns the template at the end of the chain, which does not extend another template,
+// as well as the blocks that override its block expressions. Blocks of extending templates take precedence over
+// blocks of the same names in the templates they extend. If prog does not extend another template, it is returned
+// as is.
+func (r *Renderer) resolveExtends(name string, prog *ast.Program) (*ast.Program, map[string]ast.Block, error) {
+	blocks := map[string]ast.Block{}
+	seen := map[string]struct{}{
+		name: {},
+	}
+
+	for {
+		ext := extendsStatement(prog)
+		if ext == nil {
+			return prog, blocks, nil
+		}
+
+		collectBlocks(prog.Statements, blocks)
+
+		if _, ok := seen[ext.Name]; ok {
+			return nil, nil, fmt.Errorf("cyclic extends of template %s in template %s", ext.Name, name)
+		}
+
+		seen[ext.Name] = struct{}{}
+		name = ext.Name
+
+		p, err := r.program(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		prog = p
+	}
+}
+
+// extendsStatement returns the top-level extends statement of prog, or nil if there is none.
+func extendsStatement(prog *ast.Program) *ast.ExtendsStatement {
+	for _, st := range prog.Statements {
+		if ext, ok := st.(*ast.ExtendsStatement); ok {
+			return ext
+		}
+	}
+	return nil
+}
+
+// collectBlocks stores the blocks of all block expressions in statements into blocks, including nested block
+// expressions. Blocks already stored are not overwritten.
+func collectBlocks(statements []ast.Statement, blocks map[string]ast.Block) {
+	for _, st := range statements {
+		es, ok := st.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+
+		b, ok := es.Expression.(*ast.BlockExpression)
+		if !ok {
+			continue
+		}
+
+		if _, ok := blocks[b.Name]; !ok {
+			blocks[b.Name] = b.Block
+		}
+
+		collectBlocks(b.Statements, blocks)
+	}
+}
+
+// program returns the parsed template with a specific name, either from the cache, or by loading and parsing it
+// (see Compile.)
+func (r *Renderer) program(name string) (*ast.Program, error) {
+	t, err := r.Compile(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.prog, nil
+}
+
+// load loads and parses the template with a specific name, bypassing the cache.
+func (r *Renderer) load(name string) (*ast.Program, error) {
+	rd, err := r.loader.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	prog, err := parse(rd)
+	if err != nil {
+		return nil, &TemplateError{Name: name, Err: err}
+	}
+
+	return prog, nil
+}
+
 // Render loads a template from r, evaluates it using scope s, optionally passing additional data,
 // and writes the output to w.
+//
+// The data is read-only for the template, that is, trying to assign a new value to an identifier of the data
+// results in an error.
 func Render(r io.Reader, w io.Writer, data map[string]interface{}, s *scope.Scope, evaluatorOpts ...evaluator.Opt) error {
+	return render(r, w, data, s, nil, nil, evaluatorOpts...)
+}
+
+// RenderString evaluates the template tmpl using scope s, optionally passing additional data, and returns the output
+// as a string. See Render for details.
+func RenderString(tmpl string, data map[string]interface{}, s *scope.Scope, evaluatorOpts ...evaluator.Opt) (string, error) {
+	buf := strings.Builder{}
+	if err := Render(strings.NewReader(tmpl), &buf, data, s, evaluatorOpts...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func render(r io.Reader, w io.Writer, data map[string]interface{}, s *scope.Scope, escaper escapeFunc, sm *SourceMap,
+	evaluatorOpts ...evaluator.Opt) error {
+	prog, err := parse(r)
+	if err != nil {
+		return err
+	}
+
+	return renderParsed(prog, w, data, s, escaper, sm, evaluatorOpts...)
+}
+
+// renderParsed is the same as render, but evaluates the already parsed template prog.
+func renderParsed(prog *ast.Program, w io.Writer, data map[string]interface{}, s *scope.Scope, escaper escapeFunc, sm *SourceMap,
+	evaluatorOpts ...evaluator.Opt) error {
 	templateScope := newTemplateScope(data, s)
+	evaluatorOpts = templateEvaluatorOpts(templateScope, evaluatorOpts)
+
+	o, err := evaluate(prog, templateScope, evaluatorOpts...)
+	if err != nil {
+		return err
+	}
 
-	evaluatorOpts = append(
+	if sm != nil {
+		return writeWithSourceMap(w, o, prog.Statements, escaper, sm)
+	}
+
+	return write(w, o, escaper)
+}
+
+// streamParsed is the same as renderParsed, but writes the output of each top-level statement of prog to w as soon
+// as the statement has been evaluated. It stops with an error as soon as writing fails or ctx is done.
+func streamParsed(ctx context.Context, prog *ast.Program, w io.Writer, data map[string]interface{}, s *scope.Scope,
+	escaper escapeFunc, sm *SourceMap, evaluatorOpts ...evaluator.Opt) error {
+	templateScope := newTemplateScope(data, s)
+	evaluatorOpts = templateEvaluatorOpts(templateScope, evaluatorOpts)
+
+	// same as the scope that would be created by capture
+	stmtScope := &scope.Scope{
+		Parent: templateScope,
+	}
+
+	ev := evaluator.New(evaluatorOpts...)
+
+	for i, st := range prog.Statements {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		o, err := ev.Eval(st, stmtScope)
+		if err != nil {
+			return err
+		}
+
+		if sm != nil {
+			err = writeWithSourceMap(w, o, prog.Statements[i:i+1], escaper, sm)
+		} else {
+			err = write(w, o, escaper)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// templateEvaluatorOpts returns evaluatorOpts, preceded by an option that resolves templateScope as an argument
+// to method or function calls.
+func templateEvaluatorOpts(templateScope *scope.Scope, evaluatorOpts []evaluator.Opt) []evaluator.Opt {
+	return append(
 		[]evaluator.Opt{
 			evaluator.WithArgumentResolver(evaluator.ArgumentResolverFunc(func(t reflect.Type) (interface{}, error) {
 				return resolveScope(t, templateScope)
@@ -163,51 +669,70 @@ func Render(r io.Reader, w io.Writer, data map[string]interface{}, s *scope.Scop
 		},
 		evaluatorOpts...,
 	)
+}
 
-	o, err := render(r, templateScope, evaluatorOpts...)
-	if err != nil {
-		return err
+// escapeHTML is an Escaper that converts v to a string and escapes any special characters for HTML-safe output.
+func escapeHTML(v interface{}) SafeString {
+	return SafeString(html.EscapeString(fmt.Sprint(v)))
+}
+
+// escapeWith returns an escapeFunc that passes values through e.
+func escapeWith(e Escaper) escapeFunc {
+	return func(v interface{}) (SafeString, error) {
+		return e(v), nil
 	}
+}
 
-	return write(w, o)
+// escapeWithPlaceholder returns an escapeFunc that replaces values with p.
+func escapeWithPlaceholder(p string) escapeFunc {
+	return func(v interface{}) (SafeString, error) {
+		return SafeString(p), nil
+	}
 }
 
 func (s SafeString) String() string {
 	return string(s)
 }
 
+// newTemplateScope returns a new scope for a template, with parent scope parent. The data is stored in a locked
+// scope in between, so that templates cannot overwrite it.
 func newTemplateScope(data map[string]interface{}, parent *scope.Scope) *scope.Scope {
-	s := scope.Scope{
-		Parent: parent,
-	}
+	// set the data before setting the parent, so that values are not stored in the parent instead
+	dataScope := scope.Scope{}
 
 	for k, v := range data {
 		if v != nil {
-			s.Set(k, v)
+			dataScope.Set(k, v)
 		}
 	}
 
-	return &s
+	dataScope.Lock()
+	dataScope.Parent = parent
+
+	return &scope.Scope{
+		Parent: &dataScope,
+	}
 }
 
-func render(r io.Reader, s *scope.Scope, evaluatorOpts ...evaluator.Opt) (interface{}, error) {
+func parse(r io.Reader) (*ast.Program, error) {
 	l := lexer.New(r)
 	tCh, doneCh := l.Tokens()
 
 	p := parser.New(tCh, doneCh)
-	prog, err := p.Parse()
-	if err != nil {
-		return nil, err
-	}
+	return p.Parse()
+}
 
+// evaluate evaluates the template prog and returns its output. prog is not modified, so that it can be evaluated
+// again later.
+func evaluate(prog *ast.Program, s *scope.Scope, evaluatorOpts ...evaluator.Opt) (interface{}, error) {
 	// wrap capture around the original statements to capture all output
-	prog = &ast.Program{
+	captured := &ast.Program{
 		Statements: []ast.Statement{
 			capture(prog.Statements),
 		},
 	}
 
-	return renderProgram(prog, s, evaluatorOpts...)
+	return renderProgram(captured, s, evaluatorOpts...)
 }
 
 func renderProgram(p *ast.Program, s *scope.Scope, evaluatorOpts ...evaluator.Opt) (interface{}, error) {
@@ -239,44 +764,104 @@ func resolveScope(t reflect.Type, s *scope.Scope) (interface{}, error) {
 	return s, nil
 }
 
-func write(w io.Writer, o interface{}) error {
+func write(w io.Writer, o interface{}, escaper escapeFunc) error {
 	if sl, ok := o.([]interface{}); ok {
 		for _, el := range sl {
-			if err := writeSingle(w, el); err != nil {
+			if err := writeSingle(w, el, escaper); err != nil {
 				return err
 			}
 		}
 		return nil
 	}
-	return writeSingle(w, o)
+	return writeSingle(w, o, escaper)
+}
+
+// writeWithSourceMap is the same as write, but records the output of each of the statements stmts into sm.
+// o must be the captured output of stmts. Mappings are appended to sm, continuing after its last mapping.
+func writeWithSourceMap(w io.Writer, o interface{}, stmts []ast.Statement, escaper escapeFunc, sm *SourceMap) error {
+	var os []interface{}
+
+	switch len(stmts) {
+	case 0:
+		return nil
+	case 1:
+		// capture does not wrap the output of a single statement in a slice
+		os = []interface{}{o}
+	default:
+		os = o.([]interface{})
+	}
+
+	pos := 0
+	if len(*sm) > 0 {
+		pos = (*sm)[len(*sm)-1].End
+	}
+
+	for i, el := range os {
+		s, err := expectSafe(el, escaper)
+		if err != nil {
+			return err
+		}
+		if s == "" {
+			continue
+		}
+
+		n, err := w.Write([]byte(s))
+		if err != nil {
+			return err
+		}
+
+		*sm = append(*sm, SourceMapping{
+			Start: pos,
+			End:   pos + n,
+			Line:  stmts[i].Line(),
+			Col:   stmts[i].Col(),
+		})
+
+		pos += n
+	}
+
+	return nil
 }
 
-func writeSingle(w io.Writer, o interface{}) error {
-	s := expectSafe(o)
-	_, err := w.Write([]byte(s))
+func writeSingle(w io.Writer, o interface{}, escaper escapeFunc) error {
+	s, err := expectSafe(o, escaper)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(s))
 	return err
 }
 
-func expectSafe(v interface{}) string {
+// expectSafe returns v as a string if it is a SafeString. If it is not, and escaper is not nil,
+// v is passed through escaper instead, otherwise "!UNSAFE!" is returned.
+func expectSafe(v interface{}, escaper escapeFunc) (string, error) {
 	switch value := v.(type) {
 	case nil:
-		return ""
+		return "", nil
 	case SafeString:
-		return value.String()
+		return value.String(), nil
 	case []interface{}:
 		buf := strings.Builder{}
 		for _, el := range value {
-			buf.WriteString(expectSafe(el))
+			s, err := expectSafe(el, escaper)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(s)
 		}
-		return buf.String()
+		return buf.String(), nil
 	case string:
-		if value != "" {
-			return "!UNSAFE!"
+		if value == "" {
+			return "", nil
 		}
-	default:
-		return "!UNSAFE!"
 	}
-	return ""
+
+	if escaper != nil {
+		s, err := escaper(v)
+		return s.String(), err
+	}
+
+	return defaultUnsafePlaceholder, nil
 }
 
 func (l LoaderFunc) Load(name string) (io.ReadCloser, error) {
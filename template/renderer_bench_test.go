@@ -1,6 +1,9 @@
 package template
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"strconv"
 	"strings"
 	"testing"
@@ -57,3 +60,46 @@ end
 		Result = res.(string)
 	}
 }
+
+func BenchmarkRenderer_Render(b *testing.B) {
+	benchmarkRendererRender(b)
+}
+
+func BenchmarkRenderer_Render_Cache(b *testing.B) {
+	benchmarkRendererRender(b, WithCache())
+}
+
+func benchmarkRendererRender(b *testing.B, opts ...Opt) {
+	b.Helper()
+
+	tmpl := `<ul>
+<% for i in fromTo(1, 20) %>
+	<li><% safe(i) %></li>
+<% end %>
+</ul>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	safe := func(i int) SafeString {
+		return SafeString(strconv.Itoa(i))
+	}
+
+	opts = append(opts, WithScopeData("safe", safe), WithScopeData("fromTo", ranger.NewFromTo))
+	r := NewRenderer(l, opts...)
+
+	buf := bytes.Buffer{}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+
+		if err := r.Render(context.Background(), &buf, "tmpl", nil); err != nil {
+			b.Fatalf("error while rendering: %v", err)
+		}
+	}
+
+	Result = buf.String()
+}
@@ -3,12 +3,17 @@ package template
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"html"
 	"io"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/matryer/is"
 
+	"github.com/blizzy78/copper/ast"
 	"github.com/blizzy78/copper/evaluator"
 	"github.com/blizzy78/copper/scope"
 )
@@ -51,6 +56,771 @@ func TestRenderer_Render(t *testing.T) {
 	is.Equal(valueFromCtx, "value")
 }
 
+func TestRenderer_Render_ReadOnlyData(t *testing.T) {
+	is := is.New(t)
+
+	tmpls := map[string]string{
+		"data":      `<% let name = "changed" %>`,
+		"scopeData": `<% let safe = nil %>`,
+		"nested":    `<% if true let name = "changed" end %>`,
+		"local":     `<% let other = name + "!" %><% safe(other) %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpls[name])), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	tests := []struct {
+		name        string
+		expectedErr string
+	}{
+		{"data", "cannot assign to read-only identifier: name"},
+		{"scopeData", "cannot assign to read-only identifier: safe"},
+		{"nested", "cannot assign to read-only identifier: name"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		err := r.Render(context.Background(), &buf, test.name, map[string]interface{}{
+			"name": "original",
+		})
+		is.True(evaluator.IsEvaluationError(err))
+		is.True(strings.Contains(err.Error(), test.expectedErr))
+	}
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "local", map[string]interface{}{
+		"name": "original",
+	})
+	is.NoErr(err)
+	is.Equal(buf.String(), "original!")
+}
+
+func TestRenderer_Render_ReadOnlyHostData(t *testing.T) {
+	is := is.New(t)
+
+	type user struct {
+		Name string
+	}
+
+	tmpls := map[string]string{
+		"map":       `<% let m.a = 2 %>`,
+		"mapNoLet":  `<% m["a"] = 2 %>`,
+		"slice":     `<% let sl[0] = 2 %>`,
+		"struct":    `<% u.Name = "changed" %>`,
+		"scopeData": `<% sm.a = 2 %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpls[name])), nil
+	})
+
+	sm := map[string]interface{}{"a": 1}
+	r := NewRenderer(l, WithScopeData("sm", sm))
+
+	for name := range tmpls {
+		m := map[string]interface{}{"a": 1}
+		sl := []int{1}
+		u := &user{Name: "original"}
+
+		err := r.Render(context.Background(), &bytes.Buffer{}, name, map[string]interface{}{
+			"m":  m,
+			"sl": sl,
+			"u":  u,
+		})
+		is.True(evaluator.IsEvaluationError(err))
+		is.True(strings.Contains(err.Error(), "cannot assign into read-only host value"))
+
+		is.Equal(m["a"], 1)
+		is.Equal(sl[0], 1)
+		is.Equal(u.Name, "original")
+		is.Equal(sm["a"], 1)
+	}
+}
+
+func TestRenderer_Render_TemplateFuncData(t *testing.T) {
+	is := is.New(t)
+
+	templates := map[string]string{
+		"parent":       `<% let local = "l" %><% t("child", { "x": safe(local + p) }) %>|<% safe(local) %>`,
+		"child":        `<% x %><% g %><% let local = "c" %><% safe(local) %>`,
+		"child-local":  `<% local %>`,
+		"child-parent": `<% p %>`,
+		"child-modify": `<% let x = 1 %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(templates[name])), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe), WithScopeData("g", SafeString("g")))
+
+	data := map[string]interface{}{
+		"p": "p",
+	}
+
+	res, err := r.RenderToString(context.Background(), "parent", data)
+	is.NoErr(err)
+	is.Equal(res, "lpgc|l") // child sees passed data and scope data, parent's local is not overwritten
+
+	// child cannot see parent's locals or data, and cannot modify its data
+	tests := []struct {
+		child       string
+		expectedErr string
+	}{
+		{"child-local", "error rendering template parent → child-local: evaluation error at line 1, column 4: identifier not found in scope: local"},
+		{"child-parent", "error rendering template parent → child-parent: evaluation error at line 1, column 4: identifier not found in scope: p"},
+		{"child-modify", "error rendering template parent → child-modify: evaluation error at line 1, column 4: cannot assign to read-only identifier: x"},
+	}
+
+	for _, test := range tests {
+		templates["parent"] = `<% let local = "l" %><% t("` + test.child + `", { "x": 1 }) %>`
+
+		_, err = r.RenderToString(context.Background(), "parent", data)
+		is.True(err != nil)
+		is.Equal(err.Error(), test.expectedErr)
+	}
+}
+
+func TestRenderer_Render_TemplateChain(t *testing.T) {
+	is := is.New(t)
+
+	templates := map[string]string{
+		"a": `<% t("b", nil) %>`,
+		"b": `x<% t("c", nil) %>`,
+		"c": `<% y %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(templates[name])), nil
+	})
+
+	r := NewRenderer(l)
+
+	_, err := r.RenderToString(context.Background(), "a", nil)
+	is.Equal(err.Error(), "error rendering template a → b → c: evaluation error at line 1, column 4: identifier not found in scope: y")
+
+	var te *TemplateError
+	is.True(errors.As(err, &te))
+	is.Equal(te.Chain(), []string{"a", "b", "c"})
+
+	templates["c"] = `<% if %>`
+
+	_, err = r.RenderToString(context.Background(), "a", nil)
+	is.True(errors.As(err, &te))
+	is.Equal(te.Chain(), []string{"a", "b", "c"})
+}
+
+func TestTemplateError_Wrapped(t *testing.T) {
+	is := is.New(t)
+
+	err := &TemplateError{
+		Name: "a",
+		Err: fmt.Errorf("including b: %w", &TemplateError{
+			Name: "b",
+			Err: fmt.Errorf("in block content: %w", fmt.Errorf("extending c: %w", &TemplateError{
+				Name: "c",
+				Err:  errors.New("boom"),
+			})),
+		}),
+	}
+
+	is.Equal(err.Error(), "error rendering template a (including b) → b (in block content: extending c) → c: boom")
+	is.Equal(err.Chain(), []string{"a", "b", "c"})
+
+	var te *TemplateError
+	is.True(errors.As(err.Err, &te))
+	is.Equal(te.Error(), "error rendering template b (in block content: extending c) → c: boom")
+}
+
+func TestRenderer_RenderEach(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<tr><td><% safe(name) %></td></tr>`
+
+	loads := 0
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		loads++
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	buf := bytes.Buffer{}
+	err := r.RenderEach(context.Background(), &buf, "row", []map[string]interface{}{
+		{"name": "a"},
+		{"name": "b"},
+		{"name": "c"},
+	})
+	is.NoErr(err)
+	is.Equal(buf.String(), "<tr><td>a</td></tr><tr><td>b</td></tr><tr><td>c</td></tr>")
+	is.Equal(loads, 1) // loaded only once for all items
+}
+
+func TestRenderer_RenderEach_Error(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% safe(name) %>`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	err := r.RenderEach(context.Background(), &bytes.Buffer{}, "row", []map[string]interface{}{
+		{"name": "a"},
+		{},
+	})
+	is.Equal(err.Error(), "error rendering item 1: error rendering template row: evaluation error at line 1, column 9: identifier not found in scope: name")
+}
+
+func TestRenderer_RenderWithSourceMap(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<p><% safe(name) %></p>
+<% let x = 1 %><% if x == 1 %>yes<% end %>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	buf := bytes.Buffer{}
+	sm, err := r.RenderWithSourceMap(context.Background(), &buf, "tmpl", map[string]interface{}{
+		"name": "foo",
+	})
+	is.NoErr(err)
+	is.Equal(buf.String(), "<p>foo</p>\nyes")
+	is.Equal(sm, SourceMap{
+		{Start: 0, End: 3, Line: 1, Col: 1},    // <p>
+		{Start: 3, End: 6, Line: 1, Col: 7},    // safe(name)
+		{Start: 6, End: 11, Line: 1, Col: 20},  // </p>\n
+		{Start: 11, End: 14, Line: 2, Col: 19}, // if
+	})
+}
+
+type limitWriter struct {
+	n   int
+	err error
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, w.err
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestRenderer_Render_Streaming(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<% let x = 1 %><% tick() %><% tick() %><% tick() %><% tick() %><% tick() %>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	ticks := 0
+	tick := func() SafeString {
+		ticks++
+		return "x"
+	}
+
+	errWrite := errors.New("write error")
+
+	r := NewRenderer(l, WithScopeData("tick", tick))
+	err := r.Render(context.Background(), &limitWriter{n: 2, err: errWrite}, "tmpl", nil)
+	is.True(errors.Is(err, errWrite))
+	is.Equal(ticks, 5) // evaluates everything before writing
+
+	ticks = 0
+
+	r = NewRenderer(l, WithScopeData("tick", tick), WithStreaming())
+	err = r.Render(context.Background(), &limitWriter{n: 2, err: errWrite}, "tmpl", nil)
+	is.True(errors.Is(err, errWrite))
+	is.Equal(ticks, 3) // stops after the first failed write
+}
+
+func TestRenderer_Render_Streaming_Canceled(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% cancel() %><% cancel() %><% cancel() %>`)), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+
+	r := NewRenderer(l, WithStreaming(), WithScopeData("cancel", func() {
+		calls++
+		cancel()
+	}))
+
+	buf := bytes.Buffer{}
+	err := r.Render(ctx, &buf, "tmpl", nil)
+	is.True(errors.Is(err, context.Canceled))
+	is.Equal(calls, 1)
+}
+
+func TestRenderer_RenderWithSourceMap_Streaming(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<p><% safe(name) %></p>
+<% let x = 1 %><% if x == 1 %>yes<% end %>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe), WithStreaming())
+
+	buf := bytes.Buffer{}
+	sm, err := r.RenderWithSourceMap(context.Background(), &buf, "tmpl", map[string]interface{}{
+		"name": "foo",
+	})
+	is.NoErr(err)
+	is.Equal(buf.String(), "<p>foo</p>\nyes")
+	is.Equal(sm, SourceMap{
+		{Start: 0, End: 3, Line: 1, Col: 1},    // <p>
+		{Start: 3, End: 6, Line: 1, Col: 7},    // safe(name)
+		{Start: 6, End: 11, Line: 1, Col: 20},  // </p>\n
+		{Start: 11, End: 14, Line: 2, Col: 19}, // if
+	})
+}
+
+func TestRenderer_Render_FuncMap(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% safe(upper(x)) %>-<% safe(twice(x)) %>-<% y %>`)), nil
+	})
+
+	r := NewRenderer(l,
+		WithFuncMap(map[string]interface{}{
+			"safe":  safe,
+			"upper": strings.ToUpper,
+		}),
+		WithFuncMap(map[string]interface{}{
+			"twice": func(s string) string {
+				return s + s
+			},
+		}),
+		WithScopeData("y", SafeString("y")),
+	)
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+		"x": "foo",
+	})
+	is.NoErr(err)
+	is.Equal(res, "FOO-foofoo-y")
+}
+
+func TestRenderer_Render_UnsafePlaceholder(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`a<% x %>b<% safe(x) %>`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe), WithUnsafePlaceholder("?"))
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+		"x": "<b>",
+	})
+	is.NoErr(err)
+	is.Equal(res, "a?b<b>")
+}
+
+func TestRenderer_Render_UnsafeError(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`a<% safe(x) %><% if true %><% x %><% end %>`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe), WithUnsafeError(), WithUnsafePlaceholder("?"))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "tmpl", map[string]interface{}{
+		"x": "<b>",
+	})
+	is.True(errors.Is(err, ErrUnsafeOutput))
+	is.Equal(err.Error(), "error rendering template tmpl: output of value that is not a SafeString: string")
+
+	r = NewRenderer(l, WithScopeData("safe", safe), WithUnsafeError(), WithAutoEscapeHTML())
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+		"x": "<b>",
+	})
+	is.NoErr(err)
+	is.Equal(res, "a<b>&lt;b&gt;")
+}
+
+func TestRenderer_Render_Cache(t *testing.T) {
+	is := is.New(t)
+
+	loads := 0
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		loads++
+		return io.NopCloser(strings.NewReader(`<% safe(x) %>`)), nil
+	})
+
+	r := NewRenderer(l, WithCache(), WithScopeData("safe", safe))
+
+	for _, x := range []string{"a", "b"} {
+		buf := bytes.Buffer{}
+		err := r.Render(context.Background(), &buf, "tmpl", map[string]interface{}{
+			"x": x,
+		})
+		is.NoErr(err)
+		is.Equal(buf.String(), x)
+	}
+
+	is.Equal(loads, 1) // loaded only once
+
+	r.ClearCache()
+
+	err := r.Render(context.Background(), &bytes.Buffer{}, "tmpl", map[string]interface{}{
+		"x": "c",
+	})
+	is.NoErr(err)
+	is.Equal(loads, 2) // loaded again after clearing cache
+}
+
+func TestRenderer_Render_Cache_Concurrent(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% safe(x) %>`)), nil
+	})
+
+	r := NewRenderer(l, WithCache(), WithScopeData("safe", safe))
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 100)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{
+				"x": "a",
+			})
+			if err == nil && res != "a" {
+				err = fmt.Errorf("unexpected output: %s", res)
+			}
+			errs <- err
+		}()
+
+		go func() {
+			defer wg.Done()
+			r.ClearCache()
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		is.NoErr(err)
+	}
+}
+
+func TestRenderer_Compile(t *testing.T) {
+	is := is.New(t)
+
+	loads := 0
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		loads++
+		return io.NopCloser(strings.NewReader(`<p><% safe(x) %></p>`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	tmpl, err := r.Compile("tmpl")
+	is.NoErr(err)
+
+	for _, x := range []string{"a", "b", "c"} {
+		buf := bytes.Buffer{}
+		err = tmpl.Execute(context.Background(), &buf, map[string]interface{}{
+			"x": x,
+		})
+		is.NoErr(err)
+		is.Equal(buf.String(), "<p>"+x+"</p>")
+	}
+
+	is.Equal(loads, 1) // loaded only once
+}
+
+func TestRenderer_Compile_Error(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% if %>`)), nil
+	})
+
+	r := NewRenderer(l)
+
+	_, err := r.Compile("tmpl")
+	is.True(err != nil)
+}
+
+func TestRenderer_RenderProgram(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`<% safe(x) %>`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	prog := ast.NewProgram(
+		ast.NewExpressionStatement(ast.NewLiteral("<p>")),
+		ast.NewExpressionStatement(ast.NewCall(ast.NewIdent("safe"), ast.NewIdent("x"))),
+		ast.NewExpressionStatement(ast.NewCall(ast.NewIdent("t"), ast.NewStringLiteral("other"), ast.NewIdent("data"))),
+		ast.NewExpressionStatement(ast.NewLiteral("</p>")),
+	)
+
+	buf := bytes.Buffer{}
+	err := r.RenderProgram(context.Background(), &buf, prog, map[string]interface{}{
+		"x": "foo",
+		"data": map[string]interface{}{
+			"x": "bar",
+		},
+	})
+	is.NoErr(err)
+	is.Equal(buf.String(), "<p>foobar</p>")
+}
+
+func TestRenderer_Render_Extends(t *testing.T) {
+	is := is.New(t)
+
+	tmpls := map[string]string{
+		"layout": `<title><% block "title" %>default<% end %></title><% block "content" %><% end %><% block "footer" %>footer<% end %>`,
+		"page":   `<% extends "layout" %><% block "content" %>page <% safe(name) %><% end %>ignored`,
+		"sub":    `<% extends "page" %><% block "title" %>sub<% end %>`,
+		"cycle1": `<% extends "cycle2" %>`,
+		"cycle2": `<% extends "cycle1" %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpls[name])), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"layout", "<title>default</title>footer"},
+		{"page", "<title>default</title>page foofooter"},
+		{"sub", "<title>sub</title>page foofooter"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		err := r.Render(context.Background(), &buf, test.name, map[string]interface{}{
+			"name": "foo",
+		})
+		is.NoErr(err)
+		is.Equal(buf.String(), test.expected)
+	}
+
+	err := r.Render(context.Background(), &bytes.Buffer{}, "cycle1", nil)
+	is.True(err != nil)
+	is.Equal(err.Error(), "cyclic extends of template cycle1 in template cycle2")
+}
+
+func TestRenderer_Render_Extends_Cache(t *testing.T) {
+	is := is.New(t)
+
+	tmpls := map[string]string{
+		"layout": `<title><% block "title" %>default<% end %></title><% block "content" %><% end %>`,
+		"page":   `<% extends "layout" %><% block "content" %>page <% safe(name) %><% end %>`,
+		"sub":    `<% extends "page" %><% block "title" %>sub<% end %>`,
+	}
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpls[name])), nil
+	})
+
+	uncached := NewRenderer(l, WithScopeData("safe", safe))
+	cached := NewRenderer(l, WithScopeData("safe", safe), WithCache())
+
+	for _, name := range []string{"sub", "page", "sub"} {
+		data := map[string]interface{}{
+			"name": "foo",
+		}
+
+		expected, err := uncached.RenderToString(context.Background(), name, data)
+		is.NoErr(err)
+
+		res, err := cached.RenderToString(context.Background(), name, data)
+		is.NoErr(err)
+		is.Equal(res, expected)
+	}
+
+	t1, err := cached.Compile("sub")
+	is.NoErr(err)
+	t2, err := cached.Compile("sub")
+	is.NoErr(err)
+	is.True(t1 == t2) // same compiled template returned from cache
+}
+
+func TestRenderer_Render_LiteralTrim(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`|-a|b-|<% x %>-|-c-|-`)), nil
+	})
+
+	r := NewRenderer(l, WithLiteralTrim("|-"), WithScopeData("x", SafeString("-x-")))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "tmpl", nil)
+	is.NoErr(err)
+	is.Equal(buf.String(), "a|b-x-c") // code output not trimmed
+}
+
+func TestRenderer_RenderToString(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		if name == "error" {
+			return io.NopCloser(strings.NewReader(`<% y %>`)), nil
+		}
+		return io.NopCloser(strings.NewReader(`a <% safe(x) %> c`)), nil
+	})
+
+	r := NewRenderer(l, WithScopeData("safe", safe))
+
+	res, err := r.RenderToString(context.Background(), "tmpl", map[string]interface{}{"x": "b"})
+	is.NoErr(err)
+	is.Equal(res, "a b c")
+
+	_, err = r.RenderToString(context.Background(), "error", nil)
+	is.True(err != nil)
+	is.Equal(err.Error(), "error rendering template error: evaluation error at line 1, column 4: identifier not found in scope: y")
+}
+
+func TestRenderer_Render_StrictVars(t *testing.T) {
+	is := is.New(t)
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(`a<% user.name %>b`)), nil
+	})
+
+	data := map[string]interface{}{
+		"user": map[string]interface{}{},
+	}
+
+	_, err := NewRenderer(l).RenderToString(context.Background(), "tmpl", data)
+	is.True(err != nil) // strict by default
+
+	res, err := NewRenderer(l, WithStrictVars(false)).RenderToString(context.Background(), "tmpl", data)
+	is.NoErr(err)
+	is.Equal(res, "ab")
+}
+
+func TestRenderer_Render_MaxCallDepth(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<% t("self", nil) %>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l, WithMaxCallDepth(10))
+
+	buf := bytes.Buffer{}
+	err := r.Render(context.Background(), &buf, "self", nil)
+	is.True(evaluator.IsEvaluationError(err))
+	is.True(strings.Contains(err.Error(), "maximum call depth exceeded in function call to t: 10"))
+}
+
+func TestRenderer_Render_EscaperByExt(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<p><% "<b>" %></p>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l,
+		WithEscaperByExt(map[string]Escaper{
+			".html": func(v interface{}) SafeString {
+				return SafeString(html.EscapeString(fmt.Sprint(v)))
+			},
+			".txt": func(v interface{}) SafeString {
+				return SafeString(fmt.Sprint(v))
+			},
+		}),
+	)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"page.html", "<p>&lt;b&gt;</p>"},
+		{"email.txt", "<p><b></p>"},
+		{"other.xml", "<p>!UNSAFE!</p>"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		err := r.Render(context.Background(), &buf, test.name, nil)
+		is.NoErr(err)
+		is.Equal(buf.String(), test.expected)
+	}
+}
+
+func TestRenderer_Render_AutoEscapeHTML(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := `<p><% "<b>" %><% safe("<i>") %><% 1 + 2 %></p>`
+
+	l := LoaderFunc(func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(tmpl)), nil
+	})
+
+	r := NewRenderer(l,
+		WithScopeData("safe", func(s string) SafeString {
+			return SafeString(s)
+		}),
+		WithAutoEscapeHTML(),
+		WithEscaperByExt(map[string]Escaper{
+			".txt": func(v interface{}) SafeString {
+				return SafeString(fmt.Sprint(v))
+			},
+		}),
+	)
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"page.html", "<p>&lt;b&gt;<i>3</p>"},
+		{"page", "<p>&lt;b&gt;<i>3</p>"},
+		{"email.txt", "<p><b><i>3</p>"}, // escaper by extension takes precedence
+	}
+
+	for _, test := range tests {
+		res, err := r.RenderToString(context.Background(), test.name, nil)
+		is.NoErr(err)
+		is.Equal(res, test.expected)
+	}
+}
+
 func TestRender(t *testing.T) {
 	is := is.New(t)
 
@@ -75,6 +845,67 @@ func TestRender(t *testing.T) {
 	is.Equal(res, expected)
 }
 
+func TestRenderString(t *testing.T) {
+	is := is.New(t)
+
+	s := scope.Scope{}
+	s.Set("safe", safe)
+
+	ls := evaluator.LiteralStringerFunc(func(s string) (interface{}, error) {
+		return SafeString(s), nil
+	})
+
+	res, err := RenderString(`a <% safe(x) %> c`, map[string]interface{}{"x": "b"}, &s, evaluator.WithLiteralStringer(ls))
+	is.NoErr(err)
+	is.Equal(res, "a b c")
+
+	_, err = RenderString(`a <% y %> c`, nil, &s)
+	is.True(err != nil)
+	is.Equal(err.Error(), "evaluation error at line 1, column 6: identifier not found in scope: y")
+}
+
+func TestRender_Whitespace(t *testing.T) {
+	is := is.New(t)
+
+	tmpl := "<ul>\n<% whitespace trim %>\n  <li>a</li>\n  <li>b</li>\n<% whitespace keep %>\n</ul>\n"
+	expected := "<ul>\n<li>a</li>\n  <li>b</li>\n</ul>\n"
+
+	w := strings.Builder{}
+	s := scope.Scope{}
+
+	ls := evaluator.LiteralStringerFunc(func(s string) (interface{}, error) {
+		return SafeString(s), nil
+	})
+
+	err := Render(strings.NewReader(tmpl), &w, nil, &s, evaluator.WithLiteralStringer(ls))
+	is.NoErr(err)
+	is.Equal(w.String(), expected)
+}
+
+func TestRender_Whitespace_Block(t *testing.T) {
+	is := is.New(t)
+
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"a <% if false %><% whitespace trim %><% end %> b ", "a  b "},
+		{"a <% if true %><% whitespace trim %> x <% end %> b ", "a x b "},
+		{"<% whitespace trim %> a <% if true %><% whitespace keep %> x <% end %> b ", "a x b"},
+	}
+
+	ls := evaluator.LiteralStringerFunc(func(s string) (interface{}, error) {
+		return SafeString(s), nil
+	})
+
+	for _, test := range tests {
+		w := strings.Builder{}
+		err := Render(strings.NewReader(test.tmpl), &w, nil, &scope.Scope{}, evaluator.WithLiteralStringer(ls))
+		is.NoErr(err)
+		is.Equal(w.String(), test.expected)
+	}
+}
+
 func TestRender_Unsafe(t *testing.T) {
 	is := is.New(t)
 